@@ -0,0 +1,483 @@
+package couchdb
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/google/go-querystring/query"
+)
+
+// Feed types for the _changes API.
+// http://docs.couchdb.org/en/latest/api/database/changes.html
+const (
+	FeedNormal      = "normal"
+	FeedLongpoll    = "longpoll"
+	FeedContinuous  = "continuous"
+	FeedEventSource = "eventsource"
+)
+
+// Sequence is an update sequence.
+// CouchDB 1.x uses numbers while CouchDB 2.x and later use opaque strings.
+// Numbers are kept in their decimal representation.
+type Sequence string
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (s *Sequence) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*s = ""
+		return nil
+	}
+	if len(data) > 0 && data[0] == '"' {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		*s = Sequence(str)
+		return nil
+	}
+	var number json.Number
+	if err := json.Unmarshal(data, &number); err != nil {
+		return err
+	}
+	*s = Sequence(number)
+	return nil
+}
+
+// ChangesParameters is struct to define url query parameters for the _changes feed.
+// http://docs.couchdb.org/en/latest/api/database/changes.html#get--db-_changes
+type ChangesParameters struct {
+	// Feed is one of FeedNormal, FeedLongpoll, FeedContinuous and FeedEventSource.
+	Feed        *string  `url:"feed,omitempty"`
+	Since       Sequence `url:"since,omitempty"`
+	Limit       *int     `url:"limit,omitempty"`
+	Descending  *bool    `url:"descending,omitempty"`
+	IncludeDocs *bool    `url:"include_docs,omitempty"`
+	Conflicts   *bool    `url:"conflicts,omitempty"`
+	Attachments *bool    `url:"attachments,omitempty"`
+	Filter      *string  `url:"filter,omitempty"`
+	View        *string  `url:"view,omitempty"`
+	Style       *string  `url:"style,omitempty"`
+	// Heartbeat is the interval in milliseconds after which an empty line is sent.
+	Heartbeat *int `url:"heartbeat,omitempty"`
+	// Timeout is the number of milliseconds to wait for changes before the response ends.
+	Timeout     *int `url:"timeout,omitempty"`
+	SeqInterval *int `url:"seq_interval,omitempty"`
+	// DocIDs limits the feed to the given documents. It uses the _doc_ids filter.
+	DocIDs []string `url:"-"`
+	// Selector limits the feed to documents matching the Mango selector. It uses the _selector filter.
+	Selector interface{} `url:"-"`
+	// Params holds additional query parameters for custom filter functions.
+	Params url.Values `url:"-"`
+}
+
+// Change is a single row of the _changes feed.
+type Change struct {
+	Seq     Sequence        `json:"seq"`
+	ID      string          `json:"id"`
+	Changes []ChangeRev     `json:"changes"`
+	Deleted bool            `json:"deleted,omitempty"`
+	Doc     json.RawMessage `json:"doc,omitempty"`
+}
+
+// ChangeRev is a leaf revision inside a Change.
+type ChangeRev struct {
+	Rev string `json:"rev"`
+}
+
+// DecodeDoc decodes the document included with include_docs into v.
+func (c *Change) DecodeDoc(v interface{}) error {
+	if len(c.Doc) == 0 {
+		return errors.New("couchdb: change does not include a document")
+	}
+	return json.Unmarshal(c.Doc, v)
+}
+
+// ChangesResponse is response from a normal or longpoll _changes request.
+type ChangesResponse struct {
+	Results []Change `json:"results"`
+	LastSeq Sequence `json:"last_seq"`
+	Pending int      `json:"pending"`
+}
+
+// Changes returns the changes of the database.
+// The feed is normal unless the parameters ask for a longpoll feed.
+// Use Follow for continuous and eventsource feeds.
+func (db *Database) Changes(params *ChangesParameters) (*ChangesResponse, error) {
+	return db.ChangesContext(context.Background(), params)
+}
+
+// ChangesContext is like Changes but takes a context.
+func (db *Database) ChangesContext(ctx context.Context, params *ChangesParameters) (*ChangesResponse, error) {
+	feed := FeedNormal
+	if params != nil && params.Feed != nil {
+		feed = *params.Feed
+	}
+	if feed != FeedNormal && feed != FeedLongpoll {
+		return nil, fmt.Errorf("couchdb: changes does not support %s feed, use Follow", feed)
+	}
+	res, err := db.changes(ctx, params, feed)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response ChangesResponse
+	return &response, json.NewDecoder(res.Body).Decode(&response)
+}
+
+// changes makes a request to the _changes feed.
+// Feeds filtered by document ids or a selector are sent as POST requests.
+func (db *Database) changes(ctx context.Context, params *ChangesParameters, feed string) (*http.Response, error) {
+	if params == nil {
+		params = &ChangesParameters{}
+	}
+	q, err := query.Values(params)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range params.Params {
+		q[key] = values
+	}
+	q.Set("feed", feed)
+	if params.DocIDs != nil && params.Selector != nil {
+		return nil, errors.New("couchdb: changes cannot be filtered by doc ids and selector at the same time")
+	}
+	var body interface{}
+	if params.DocIDs != nil {
+		q.Set("filter", "_doc_ids")
+		body = map[string]interface{}{"doc_ids": params.DocIDs}
+	}
+	if params.Selector != nil {
+		q.Set("filter", "_selector")
+		body = map[string]interface{}{"selector": params.Selector}
+	}
+	u := fmt.Sprintf("%s/_changes?%s", url.PathEscape(db.Name), q.Encode())
+	if body == nil {
+		return db.Client.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
+	}
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(body); err != nil {
+		return nil, err
+	}
+	return db.Client.RequestContext(ctx, http.MethodPost, u, &b, "application/json")
+}
+
+// Follow opens a feed which delivers one change at a time.
+// The feed is continuous unless the parameters ask for another feed type.
+//
+// Longpoll, continuous and eventsource feeds keep following the database.
+// Whenever the server ends a response, e.g. because of Timeout, or the connection drops
+// the feed reconnects and resumes from the last seen sequence.
+// Connection failures are retried according to the client retry policy,
+// or DefaultRetryPolicy if the client has none. A normal feed and feeds with a Limit
+// end after the first response.
+//
+//	feed, err := db.Follow(params)
+//	...
+//	defer feed.Close()
+//	for feed.Next() {
+//		change := feed.Change()
+//	}
+//	if err := feed.Err(); err != nil { ... }
+func (db *Database) Follow(params *ChangesParameters) (*ChangesFeed, error) {
+	return db.FollowContext(context.Background(), params)
+}
+
+// FollowContext is like Follow but takes a context. Cancelling the context stops the feed.
+func (db *Database) FollowContext(ctx context.Context, params *ChangesParameters) (*ChangesFeed, error) {
+	feed := FeedContinuous
+	if params != nil && params.Feed != nil {
+		feed = *params.Feed
+	}
+	switch feed {
+	case FeedNormal, FeedLongpoll, FeedContinuous, FeedEventSource:
+	default:
+		return nil, fmt.Errorf("couchdb: unknown feed %q", feed)
+	}
+	f := &ChangesFeed{
+		db:     db,
+		parent: ctx,
+		feed:   feed,
+	}
+	if params != nil {
+		f.params = *params
+	}
+	f.lastSeq = f.params.Since
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	// connect right away to report errors like a missing database early
+	if err := f.connect(); err != nil {
+		f.cancel()
+		return nil, err
+	}
+	return f, nil
+}
+
+// ChangesFeed is an iterator over a _changes feed. It is not safe for concurrent use
+// except for Close, which may be called from another goroutine to stop a blocked Next.
+type ChangesFeed struct {
+	db       *Database
+	parent   context.Context
+	ctx      context.Context
+	cancel   context.CancelFunc
+	params   ChangesParameters
+	feed     string
+	body     io.ReadCloser
+	reader   *bufio.Reader
+	decoder  *json.Decoder
+	change   Change
+	lastSeq  Sequence
+	pending  int
+	err      error
+	failures int
+	done     bool
+}
+
+// Next advances the feed to the next change. It blocks until a change arrives
+// and returns false when the feed ended, was closed or failed.
+func (f *ChangesFeed) Next() bool {
+	for {
+		if f.err != nil || f.ctx.Err() != nil {
+			f.stop(nil)
+			return false
+		}
+		if f.body == nil {
+			if f.done {
+				return false
+			}
+			if err := f.connect(); err != nil {
+				if !f.reconnect(err) {
+					return false
+				}
+				continue
+			}
+		}
+		change, end, err := f.read()
+		if err != nil {
+			f.closeBody()
+			if !f.reconnect(err) {
+				return false
+			}
+			continue
+		}
+		if end {
+			f.closeBody()
+			if f.feed == FeedNormal || f.params.Limit != nil {
+				f.done = true
+			}
+			continue
+		}
+		f.failures = 0
+		f.change = change
+		if change.Seq != "" {
+			f.lastSeq = change.Seq
+		}
+		return true
+	}
+}
+
+// Change returns the current change.
+func (f *ChangesFeed) Change() Change {
+	return f.change
+}
+
+// LastSeq returns the sequence of the last change or the last_seq sent by the server.
+// Pass it as Since to resume the feed later.
+func (f *ChangesFeed) LastSeq() Sequence {
+	return f.lastSeq
+}
+
+// Pending returns the number of changes left after the last normal or longpoll response.
+func (f *ChangesFeed) Pending() int {
+	return f.pending
+}
+
+// Err returns the error which ended the feed.
+// It is nil if the feed was closed or a normal feed ended.
+func (f *ChangesFeed) Err() error {
+	return f.err
+}
+
+// Close stops the feed and closes the underlying connection.
+func (f *ChangesFeed) Close() error {
+	f.cancel()
+	return nil
+}
+
+// stop ends the feed with the given error, preferring a cancelled parent context.
+func (f *ChangesFeed) stop(err error) {
+	if f.err == nil {
+		if perr := f.parent.Err(); perr != nil {
+			f.err = perr
+		} else if f.ctx.Err() == nil {
+			f.err = err
+		}
+	}
+	f.closeBody()
+	f.done = true
+	f.cancel()
+}
+
+// reconnect waits before the next connection attempt.
+// It returns false and stops the feed if err is permanent or too many attempts failed.
+func (f *ChangesFeed) reconnect(err error) bool {
+	if f.ctx.Err() != nil {
+		f.stop(nil)
+		return false
+	}
+	policy := f.db.Client.retryPolicy(f.ctx)
+	if policy == nil || policy.MaxAttempts <= 1 {
+		policy = &DefaultRetryPolicy
+	}
+	f.failures++
+	if f.failures >= policy.MaxAttempts || !retryable(f.ctx, err) {
+		f.stop(err)
+		return false
+	}
+	if err := sleep(f.ctx, policy.backoff(f.failures, "")); err != nil {
+		f.stop(nil)
+		return false
+	}
+	return true
+}
+
+func (f *ChangesFeed) connect() error {
+	params := f.params
+	params.Since = f.lastSeq
+	res, err := f.db.changes(f.ctx, &params, f.feed)
+	if err != nil {
+		return err
+	}
+	f.body = res.Body
+	f.reader = bufio.NewReader(res.Body)
+	f.decoder = nil
+	if f.feed == FeedNormal || f.feed == FeedLongpoll {
+		f.decoder = json.NewDecoder(f.reader)
+		if err := f.openResults(); err != nil {
+			f.closeBody()
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *ChangesFeed) closeBody() {
+	if f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+}
+
+// read returns the next change or end is true if the response is complete.
+func (f *ChangesFeed) read() (change Change, end bool, err error) {
+	if f.decoder != nil {
+		return f.readResults()
+	}
+	for {
+		line, err := f.reader.ReadBytes('\n')
+		if err != nil {
+			if err == io.EOF && len(bytes.TrimSpace(line)) == 0 {
+				return change, true, nil
+			}
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return change, false, err
+		}
+		line = bytes.TrimSpace(line)
+		if f.feed == FeedEventSource {
+			// skip event, id and retry fields, only data carries changes
+			if !bytes.HasPrefix(line, []byte("data:")) {
+				continue
+			}
+			line = bytes.TrimSpace(bytes.TrimPrefix(line, []byte("data:")))
+		}
+		// empty lines are heartbeats
+		if len(line) == 0 {
+			continue
+		}
+		var row struct {
+			Change
+			LastSeq *Sequence `json:"last_seq"`
+		}
+		if err := json.Unmarshal(line, &row); err != nil {
+			return change, false, err
+		}
+		if row.LastSeq != nil {
+			f.lastSeq = *row.LastSeq
+			return change, true, nil
+		}
+		return row.Change, false, nil
+	}
+}
+
+// openResults reads the response object up to the start of the results array.
+func (f *ChangesFeed) openResults() error {
+	if err := expectDelim(f.decoder, '{'); err != nil {
+		return err
+	}
+	for f.decoder.More() {
+		key, err := f.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key == "results" {
+			return expectDelim(f.decoder, '[')
+		}
+		if err := f.decodeField(key); err != nil {
+			return err
+		}
+	}
+	return errors.New("couchdb: changes response has no results")
+}
+
+// readResults returns the next element of the results array
+// and reads the remaining fields after the last element.
+func (f *ChangesFeed) readResults() (change Change, end bool, err error) {
+	if f.decoder.More() {
+		err := f.decoder.Decode(&change)
+		return change, false, err
+	}
+	if err := expectDelim(f.decoder, ']'); err != nil {
+		return change, false, err
+	}
+	for f.decoder.More() {
+		key, err := f.decoder.Token()
+		if err != nil {
+			return change, false, err
+		}
+		if err := f.decodeField(key); err != nil {
+			return change, false, err
+		}
+	}
+	return change, true, expectDelim(f.decoder, '}')
+}
+
+// decodeField decodes the value of a top level field other than results.
+func (f *ChangesFeed) decodeField(key json.Token) error {
+	switch key {
+	case "last_seq":
+		return f.decoder.Decode(&f.lastSeq)
+	case "pending":
+		return f.decoder.Decode(&f.pending)
+	}
+	var skip json.RawMessage
+	return f.decoder.Decode(&skip)
+}
+
+// expectDelim reads the next token and makes sure it is the given delimiter.
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if d, ok := token.(json.Delim); !ok || d != delim {
+		return fmt.Errorf("couchdb: expected %s but got %v", delim, token)
+	}
+	return nil
+}
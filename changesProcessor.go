@@ -0,0 +1,251 @@
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Checkpoint is the _local document in which a ChangesProcessor stores its progress.
+type Checkpoint struct {
+	Document
+	Seq       Sequence  `json:"seq"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ChangesProcessor follows the _changes feed of a database and runs a handler for every
+// change or batch of changes. Changes are processed at least once: the sequence of the last
+// processed change is stored in the _local/<Name> document of the same database
+// and processing resumes from there after a restart.
+//
+//	p := &couchdb.ChangesProcessor{
+//		Database: db,
+//		Name:     "mailer",
+//		Params:   couchdb.ChangesParameters{IncludeDocs: pointer.Bool(true)},
+//		Handler: func(ctx context.Context, change couchdb.Change) error {
+//			...
+//		},
+//	}
+//	err := p.Run(ctx)
+type ChangesProcessor struct {
+	Database DatabaseService
+	// Name identifies the processor and its checkpoint document.
+	Name string
+	// Params are passed to Follow. Since is only used if no checkpoint exists yet.
+	Params ChangesParameters
+	// Handler is called for every change. Either Handler or BatchHandler must be set.
+	Handler func(ctx context.Context, change Change) error
+	// BatchHandler is called with up to BatchSize changes at a time.
+	BatchHandler func(ctx context.Context, changes []Change) error
+	// BatchSize is the maximum number of changes passed to BatchHandler. Defaults to 100.
+	BatchSize int
+	// CheckpointEvery saves a checkpoint after the given number of processed changes. Defaults to 100.
+	CheckpointEvery int
+	// CheckpointInterval saves a checkpoint after the given duration if changes have been processed.
+	// Partial batches are handed to BatchHandler at the same interval. Defaults to 10s.
+	CheckpointInterval time.Duration
+	// Retry is the policy for retrying failed handlers. Defaults to DefaultRetryPolicy.
+	Retry *RetryPolicy
+}
+
+const (
+	defaultBatchSize          = 100
+	defaultCheckpointEvery    = 100
+	defaultCheckpointInterval = 10 * time.Second
+)
+
+// Run processes changes until the context is cancelled, the feed ends or a handler
+// keeps failing after all retries. A final checkpoint is saved in every case.
+func (p *ChangesProcessor) Run(ctx context.Context) error {
+	if p.Name == "" {
+		return errors.New("couchdb: changes processor needs a name")
+	}
+	if (p.Handler == nil) == (p.BatchHandler == nil) {
+		return errors.New("couchdb: changes processor needs either a handler or a batch handler")
+	}
+	checkpoint, err := p.Checkpoint(ctx)
+	if err != nil {
+		return err
+	}
+	params := p.Params
+	if checkpoint.Seq != "" {
+		params.Since = checkpoint.Seq
+	}
+	feedCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	feed, err := p.Database.FollowContext(feedCtx, &params)
+	if err != nil {
+		return err
+	}
+	defer feed.Close()
+
+	batchSize := 1
+	if p.BatchHandler != nil {
+		batchSize = p.BatchSize
+		if batchSize <= 0 {
+			batchSize = defaultBatchSize
+		}
+	}
+	every := p.CheckpointEvery
+	if every <= 0 {
+		every = defaultCheckpointEvery
+	}
+	interval := p.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+
+	changes := make(chan Change, batchSize)
+	go func() {
+		defer close(changes)
+		for feed.Next() {
+			select {
+			case changes <- feed.Change():
+			case <-feedCtx.Done():
+				return
+			}
+		}
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var batch []Change
+	var processed Sequence
+	unsaved := 0
+	// save stores the checkpoint even if the context has been cancelled
+	save := func() error {
+		if unsaved == 0 {
+			return nil
+		}
+		checkpoint.Seq = processed
+		if err := p.saveCheckpoint(context.Background(), checkpoint); err != nil {
+			return err
+		}
+		unsaved = 0
+		return nil
+	}
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := p.handle(ctx, batch); err != nil {
+			return err
+		}
+		processed = batch[len(batch)-1].Seq
+		unsaved += len(batch)
+		batch = nil
+		return nil
+	}
+	// finish saves the progress made so far and returns err
+	finish := func(err error) error {
+		if serr := save(); err == nil {
+			err = serr
+		}
+		return err
+	}
+	for {
+		select {
+		case change, ok := <-changes:
+			if !ok {
+				if ctx.Err() != nil {
+					return finish(ctx.Err())
+				}
+				if err := flush(); err != nil {
+					return finish(err)
+				}
+				return finish(feed.Err())
+			}
+			batch = append(batch, change)
+			if len(batch) < batchSize {
+				continue
+			}
+			if err := flush(); err != nil {
+				return finish(err)
+			}
+			if unsaved >= every {
+				if err := save(); err != nil {
+					return err
+				}
+			}
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				return finish(err)
+			}
+			if err := save(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return finish(ctx.Err())
+		}
+	}
+}
+
+// handle runs the handler for the given changes and retries it with backoff.
+func (p *ChangesProcessor) handle(ctx context.Context, batch []Change) error {
+	policy := p.Retry
+	if policy == nil {
+		policy = &DefaultRetryPolicy
+	}
+	for attempt := 1; ; attempt++ {
+		var err error
+		if p.BatchHandler != nil {
+			err = p.BatchHandler(ctx, batch)
+		} else {
+			err = p.Handler(ctx, batch[0])
+		}
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		retry := attempt < policy.MaxAttempts
+		var delay time.Duration
+		if retry {
+			delay = policy.backoff(attempt, "")
+		}
+		if policy.OnAttempt != nil {
+			policy.OnAttempt(RetryAttempt{
+				Attempt: attempt,
+				Err:     err,
+				Retry:   retry,
+				Delay:   delay,
+			})
+		}
+		if !retry {
+			return fmt.Errorf("couchdb: processing change %s: %w", batch[0].Seq, err)
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// Checkpoint returns the stored checkpoint of the processor.
+// The checkpoint has an empty sequence if the processor never saved one.
+func (p *ChangesProcessor) Checkpoint(ctx context.Context) (*Checkpoint, error) {
+	checkpoint := &Checkpoint{}
+	err := p.Database.GetContext(ctx, checkpoint, p.checkpointID())
+	if IsNotFound(err) {
+		return &Checkpoint{Document: Document{ID: p.checkpointID()}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return checkpoint, nil
+}
+
+func (p *ChangesProcessor) saveCheckpoint(ctx context.Context, checkpoint *Checkpoint) error {
+	checkpoint.UpdatedAt = time.Now().UTC()
+	res, err := p.Database.PutContext(ctx, checkpoint)
+	if err != nil {
+		return err
+	}
+	checkpoint.Rev = res.Rev
+	return nil
+}
+
+func (p *ChangesProcessor) checkpointID() string {
+	return "_local/" + p.Name
+}
@@ -2,59 +2,85 @@ package couchdb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"io/fs"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
 // Client holds all info for database client
 type Client struct {
-	Username  string
-	Password  string
-	BaseURL   *url.URL
-	CookieJar *cookiejar.Jar
+	Username   string
+	Password   string
+	BaseURL    *url.URL
+	CookieJar  *cookiejar.Jar
+	HTTPClient *http.Client
+	UserAgent  string
+	// Retry is the retry policy for transient failures. Requests are not retried if it is nil.
+	Retry *RetryPolicy
 }
 
 // NewClient returns new couchdb client for given url
-func NewClient(u *url.URL) (*Client, error) {
+func NewClient(u *url.URL, opts ...ClientOption) (*Client, error) {
+	return NewAuthClient("", "", u, opts...)
+}
+
+// NewAuthClient returns new couchdb client with basic authentication
+func NewAuthClient(username, password string, u *url.URL, opts ...ClientOption) (*Client, error) {
 	jar, err := cookiejar.New(nil)
 	if err != nil {
 		return nil, err
 	}
 	c := &Client{
-		Username:  "",
-		Password:  "",
-		BaseURL:   u,
-		CookieJar: jar,
+		Username:   username,
+		Password:   password,
+		BaseURL:    u,
+		CookieJar:  jar,
+		HTTPClient: &http.Client{},
+	}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+	// add cookies
+	if c.HTTPClient.Jar == nil {
+		c.HTTPClient.Jar = jar
 	}
 	return c, nil
 }
 
-// NewAuthClient returns new couchdb client with basic authentication
-func NewAuthClient(username, password string, u *url.URL) (*Client, error) {
-	jar, err := cookiejar.New(nil)
-	if err != nil {
-		return nil, err
+// httpClient returns the long-lived http client.
+// It falls back to a fresh client for Client values not created by NewClient.
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
 	}
-	return &Client{
-		Username:  username,
-		Password:  password,
-		BaseURL:   u,
-		CookieJar: jar,
-	}, nil
+	if c.CookieJar != nil {
+		return &http.Client{Jar: c.CookieJar}
+	}
+	return http.DefaultClient
 }
 
 // Info returns some information about the server
 func (c *Client) Info() (*Server, error) {
+	return c.InfoContext(context.Background())
+}
+
+// InfoContext is like Info but takes a context.
+func (c *Client) InfoContext(ctx context.Context) (*Server, error) {
 	u := ""
-	res, err := c.Request(http.MethodGet, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -65,8 +91,13 @@ func (c *Client) Info() (*Server, error) {
 
 // ActiveTasks returns list of currently running tasks
 func (c *Client) ActiveTasks() ([]Task, error) {
+	return c.ActiveTasksContext(context.Background())
+}
+
+// ActiveTasksContext is like ActiveTasks but takes a context.
+func (c *Client) ActiveTasksContext(ctx context.Context) ([]Task, error) {
 	u := "_active_tasks"
-	res, err := c.Request(http.MethodGet, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +108,13 @@ func (c *Client) ActiveTasks() ([]Task, error) {
 
 // All returns list of all databases on server
 func (c *Client) All() ([]string, error) {
+	return c.AllContext(context.Background())
+}
+
+// AllContext is like All but takes a context.
+func (c *Client) AllContext(ctx context.Context) ([]string, error) {
 	u := "_all_dbs"
-	res, err := c.Request(http.MethodGet, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -89,8 +125,13 @@ func (c *Client) All() ([]string, error) {
 
 // Get database.
 func (c *Client) Get(name string) (*DatabaseInfo, error) {
+	return c.GetContext(context.Background(), name)
+}
+
+// GetContext is like Get but takes a context.
+func (c *Client) GetContext(ctx context.Context, name string) (*DatabaseInfo, error) {
 	u := url.PathEscape(name)
-	res, err := c.Request(http.MethodGet, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -101,8 +142,13 @@ func (c *Client) Get(name string) (*DatabaseInfo, error) {
 
 // Create database.
 func (c *Client) Create(name string) (*DatabaseResponse, error) {
+	return c.CreateContext(context.Background(), name)
+}
+
+// CreateContext is like Create but takes a context.
+func (c *Client) CreateContext(ctx context.Context, name string) (*DatabaseResponse, error) {
 	u := url.PathEscape(name)
-	res, err := c.Request(http.MethodPut, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodPut, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -113,8 +159,13 @@ func (c *Client) Create(name string) (*DatabaseResponse, error) {
 
 // Delete database.
 func (c *Client) Delete(name string) (*DatabaseResponse, error) {
+	return c.DeleteContext(context.Background(), name)
+}
+
+// DeleteContext is like Delete but takes a context.
+func (c *Client) DeleteContext(ctx context.Context, name string) (*DatabaseResponse, error) {
 	u := url.PathEscape(name)
-	res, err := c.Request(http.MethodDelete, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodDelete, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -125,12 +176,17 @@ func (c *Client) Delete(name string) (*DatabaseResponse, error) {
 
 // CreateUser creates a new user in _users database
 func (c *Client) CreateUser(user User) (*DocumentResponse, error) {
+	return c.CreateUserContext(context.Background(), user)
+}
+
+// CreateUserContext is like CreateUser but takes a context.
+func (c *Client) CreateUserContext(ctx context.Context, user User) (*DocumentResponse, error) {
 	u := fmt.Sprintf("_users/%s", user.ID)
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(user); err != nil {
 		return nil, err
 	}
-	res, err := c.Request(http.MethodPut, u, &b, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodPut, u, &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -141,8 +197,13 @@ func (c *Client) CreateUser(user User) (*DocumentResponse, error) {
 
 // GetUser returns user by given name
 func (c *Client) GetUser(name string) (*User, error) {
+	return c.GetUserContext(context.Background(), name)
+}
+
+// GetUserContext is like GetUser but takes a context.
+func (c *Client) GetUserContext(ctx context.Context, name string) (*User, error) {
 	u := fmt.Sprintf("_users/org.couchdb.user:%s", name)
-	res, err := c.Request(http.MethodGet, u, nil, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -153,19 +214,29 @@ func (c *Client) GetUser(name string) (*User, error) {
 
 // DeleteUser removes user from database
 func (c *Client) DeleteUser(user *User) (*DocumentResponse, error) {
+	return c.DeleteUserContext(context.Background(), user)
+}
+
+// DeleteUserContext is like DeleteUser but takes a context.
+func (c *Client) DeleteUserContext(ctx context.Context, user *User) (*DocumentResponse, error) {
 	db := c.Use("_users")
-	return db.Delete(user)
+	return db.DeleteContext(ctx, user)
 }
 
 // CreateSession creates a new session and logs in user
 func (c *Client) CreateSession(name, password string) (*PostSessionResponse, error) {
+	return c.CreateSessionContext(context.Background(), name, password)
+}
+
+// CreateSessionContext is like CreateSession but takes a context.
+func (c *Client) CreateSessionContext(ctx context.Context, name, password string) (*PostSessionResponse, error) {
 	u := "_session"
 	creds := Credentials{name, password}
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(creds); err != nil {
 		return nil, err
 	}
-	res, err := c.Request(http.MethodPost, u, &b, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodPost, u, &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -176,8 +247,13 @@ func (c *Client) CreateSession(name, password string) (*PostSessionResponse, err
 
 // GetSession returns session for currently logged in user
 func (c *Client) GetSession() (*GetSessionResponse, error) {
+	return c.GetSessionContext(context.Background())
+}
+
+// GetSessionContext is like GetSession but takes a context.
+func (c *Client) GetSessionContext(ctx context.Context) (*GetSessionResponse, error) {
 	u := "_session"
-	res, err := c.Request(http.MethodGet, u, nil, "")
+	res, err := c.RequestContext(ctx, http.MethodGet, u, nil, "")
 	if err != nil {
 		return nil, err
 	}
@@ -188,8 +264,13 @@ func (c *Client) GetSession() (*GetSessionResponse, error) {
 
 // DeleteSession removes current session and logs out user
 func (c *Client) DeleteSession() (*DatabaseResponse, error) {
+	return c.DeleteSessionContext(context.Background())
+}
+
+// DeleteSessionContext is like DeleteSession but takes a context.
+func (c *Client) DeleteSessionContext(ctx context.Context) (*DatabaseResponse, error) {
 	u := "_session"
-	res, err := c.Request(http.MethodDelete, u, nil, "")
+	res, err := c.RequestContext(ctx, http.MethodDelete, u, nil, "")
 	if err != nil {
 		return nil, err
 	}
@@ -309,11 +390,16 @@ type Replication struct {
 //
 // http://docs.couchdb.org/en/1.6.1/api/server/common.html#replicate
 func (c *Client) Replicate(req ReplicationRequest) (*ReplicationResponse, error) {
+	return c.ReplicateContext(context.Background(), req)
+}
+
+// ReplicateContext is like Replicate but takes a context.
+func (c *Client) ReplicateContext(ctx context.Context, req ReplicationRequest) (*ReplicationResponse, error) {
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(req); err != nil {
 		return nil, err
 	}
-	res, err := c.Request(http.MethodPost, "_replicate", &b, "application/json")
+	res, err := c.RequestContext(ctx, http.MethodPost, "_replicate", &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -324,106 +410,612 @@ func (c *Client) Replicate(req ReplicationRequest) (*ReplicationResponse, error)
 
 // Request creates new http request and does it.
 func (c *Client) Request(method, uri string, data io.Reader, contentType string) (*http.Response, error) {
+	return c.RequestContext(context.Background(), method, uri, data, contentType)
+}
+
+// RequestContext is like Request but takes a context.
+// Cancelling the context or reaching its deadline aborts the request,
+// including reading the response body.
+// Transient failures are retried according to the client retry policy
+// or the policy attached with ContextWithRetryPolicy.
+func (c *Client) RequestContext(ctx context.Context, method, uri string, data io.Reader, contentType string) (*http.Response, error) {
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return c.request(ctx, method, uri, data, header)
+}
+
+// request is like RequestContext but sends the given headers.
+func (c *Client) request(ctx context.Context, method, uri string, data io.Reader, header http.Header) (*http.Response, error) {
+	return c.do(ctx, method, uri, data, header, requestOptions{})
+}
+
+// requestOptions change how do handles a request.
+type requestOptions struct {
+	// raw returns responses with any status code instead of an *Error.
+	// Only transient errors like 503 are retried.
+	raw bool
+	// once makes a single attempt. OnAttempt of the retry policy is still called.
+	once bool
+}
+
+// do makes a request and retries transient failures according to the retry policy.
+func (c *Client) do(ctx context.Context, method, uri string, data io.Reader, header http.Header, opts requestOptions) (*http.Response, error) {
 	rel, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
 	}
 	u := c.BaseURL.ResolveReference(rel)
-	req, err := http.NewRequest(method, u.String(), data)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), data)
 	if err != nil {
 		return nil, err
 	}
-	if contentType != "" {
-		req.Header.Set("Content-Type", contentType)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
 	}
 	// basic auth
 	if c.Username != "" && c.Password != "" {
 		req.SetBasicAuth(c.Username, c.Password)
 	}
-	// add cookies
-	client := &http.Client{Jar: c.CookieJar}
-	res, err := client.Do(req)
-	if err != nil {
-		return nil, err
+	policy := c.retryPolicy(ctx)
+	attempts := 1
+	// only replay bodies which can be read again
+	if !opts.once && (data == nil || req.GetBody != nil) {
+		attempts = policy.attempts(method)
 	}
-	// handle CouchDB http errors
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, newError(res)
+	for attempt := 1; ; attempt++ {
+		r := req
+		if attempt > 1 {
+			r = req.Clone(ctx)
+			if req.GetBody != nil {
+				if r.Body, err = req.GetBody(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		statusCode := 0
+		retryAfter := ""
+		var raw *http.Response
+		res, err := c.httpClient().Do(r)
+		if err != nil {
+			err = &NetworkError{Method: method, URL: u.String(), Err: err}
+		} else {
+			// handle CouchDB http errors
+			if res.StatusCode < 200 || res.StatusCode >= 300 {
+				statusCode = res.StatusCode
+				retryAfter = res.Header.Get("Retry-After")
+				if opts.raw {
+					err = &Error{Method: method, URL: u.String(), StatusCode: res.StatusCode, Header: res.Header}
+					if !retryable(ctx, err) {
+						return res, nil
+					}
+					// keep the body for the caller unless the attempt is retried
+					raw = res
+				} else {
+					err = newError(res)
+				}
+			} else {
+				// save new cookies
+				if c.CookieJar != nil {
+					c.CookieJar.SetCookies(req.URL, res.Cookies())
+				}
+				return res, nil
+			}
+		}
+		retry := attempt < attempts && retryable(ctx, err)
+		var delay time.Duration
+		if retry {
+			delay = policy.backoff(attempt, retryAfter)
+		}
+		if policy != nil && policy.OnAttempt != nil {
+			policy.OnAttempt(RetryAttempt{
+				Attempt:    attempt,
+				Method:     method,
+				URL:        u.String(),
+				StatusCode: statusCode,
+				Err:        err,
+				Retry:      retry,
+				Delay:      delay,
+			})
+		}
+		if !retry {
+			if raw != nil {
+				return raw, nil
+			}
+			return nil, err
+		}
+		if raw != nil {
+			raw.Body.Close()
+		}
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
-	// save new cookies
-	c.CookieJar.SetCookies(req.URL, res.Cookies())
-	return res, nil
 }
 
+// Files and folders of a design document folder.
 const (
-	fileNameMap    = "map.js"
-	fileNameReduce = "reduce.js"
+	fileNameMap               = "map.js"
+	fileNameReduce            = "reduce.js"
+	fileNameValidateDocUpdate = "validate_doc_update.js"
+	fileNameOptions           = "options.json"
+	fileNameMembers           = "members.json"
+	fileNameLanguage          = "language"
+	dirNameFilters            = "filters"
+	dirNameShows              = "shows"
+	dirNameLists              = "lists"
+	dirNameUpdates            = "updates"
+	dirNameLib                = "lib"
+	extJavaScript             = ".js"
 )
 
 // Parse takes a location and parses all design documents with corresponding views.
 // The folder structure must look like this.
 //
-//   design
-//   |-- player
-//   |   |-- byAge
-//   |   |   |-- map.js
-//   |   |   `-- reduce.js
-//   |   `-- byName
-//   |       `-- map.js
-//   `-- user
-//       |-- byEmail
-//       |   |-- map.js
-//       |   `-- reduce.js
-//       `-- byUsername
-//           `-- map.js
+//	design
+//	|-- player
+//	|   |-- byAge
+//	|   |   |-- map.js
+//	|   |   |-- reduce.js
+//	|   |   `-- options.json
+//	|   `-- byName
+//	|       `-- map.js
+//	`-- user
+//	    |-- byEmail
+//	    |   |-- map.js
+//	    |   `-- reduce.js
+//	    |-- byUsername
+//	    |   `-- map.js
+//	    |-- filters
+//	    |   `-- active.js
+//	    |-- shows
+//	    |   `-- profile.js
+//	    |-- lists
+//	    |   `-- csv.js
+//	    |-- updates
+//	    |   `-- touch.js
+//	    |-- lib
+//	    |   `-- validation
+//	    |       `-- email.js
+//	    |-- validate_doc_update.js
+//	    |-- options.json
+//	    |-- members.json
+//	    `-- language
+//
+// Every folder with a map.js file is a view and its options.json holds the view
+// options. Functions in filters, shows, lists and updates are named after their
+// files. CommonJS modules in lib become the nested lib object, e.g.
+// require("lib/validation/email"). The language file overrides the default
+// language javascript. members.json holds other members like autoupdate or
+// rewrites, see DesignDocument.Extra. Other files are ignored.
 func (c *Client) Parse(dirname string) ([]DesignDocument, error) {
+	return c.ParseFS(os.DirFS(dirname))
+}
+
+// ParseFS is like Parse but reads the design documents from the root of fsys,
+// e.g. from an embed.FS:
+//
+//	//go:embed design
+//	var design embed.FS
+//
+//	sub, err := fs.Sub(design, "design")
+//	docs, err := client.ParseFS(sub)
+func (c *Client) ParseFS(fsys fs.FS) ([]DesignDocument, error) {
 	docs := []DesignDocument{}
 	// get all directories inside location which will become separate design documents
-	dirs, err := ioutil.ReadDir(dirname)
+	dirs, err := fs.ReadDir(fsys, ".")
 	if err != nil {
 		return nil, err
 	}
 	for _, dir := range dirs {
-		designDocumentName := dir.Name()
-		ff, err := ioutil.ReadDir(filepath.Join(dirname, designDocumentName))
+		if strings.HasPrefix(dir.Name(), ".") {
+			continue
+		}
+		ok, err := isDir(fsys, dir.Name(), dir)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		d, err := parseDesignDocument(fsys, dir.Name())
 		if err != nil {
 			return nil, err
 		}
-		d := DesignDocument{
-			Document: Document{
-				ID: fmt.Sprintf("_design/%s", designDocumentName),
-			},
-			Language: langJavaScript,
-			Views:    map[string]DesignDocumentView{},
-		}
-		for _, j := range ff {
-			viewName := j.Name()
-			// create new view inside design document
-			view := DesignDocumentView{}
-			// get map function
-			pathMap := filepath.Join(dirname, designDocumentName, viewName, fileNameMap)
-			bMap, err := ioutil.ReadFile(pathMap)
+		docs = append(docs, d)
+	}
+	return docs, nil
+}
+
+func parseDesignDocument(fsys fs.FS, name string) (DesignDocument, error) {
+	d := DesignDocument{
+		Document: Document{
+			ID: fmt.Sprintf("_design/%s", name),
+		},
+		Language: langJavaScript,
+		Views:    map[string]DesignDocumentView{},
+	}
+	entries, err := fs.ReadDir(fsys, name)
+	if err != nil {
+		return d, err
+	}
+	for _, entry := range entries {
+		p := path.Join(name, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		dir, err := isDir(fsys, p, entry)
+		if err != nil {
+			return d, err
+		}
+		switch {
+		case !dir:
+			if err := parseDesignDocumentFile(fsys, p, &d); err != nil {
+				return d, err
+			}
+		case entry.Name() == dirNameFilters:
+			if d.Filters, err = readFunctions(fsys, p); err != nil {
+				return d, err
+			}
+		case entry.Name() == dirNameShows:
+			if d.Shows, err = readFunctions(fsys, p); err != nil {
+				return d, err
+			}
+		case entry.Name() == dirNameLists:
+			if d.Lists, err = readFunctions(fsys, p); err != nil {
+				return d, err
+			}
+		case entry.Name() == dirNameUpdates:
+			if d.Updates, err = readFunctions(fsys, p); err != nil {
+				return d, err
+			}
+		case entry.Name() == dirNameLib:
+			if d.Lib, err = readModules(fsys, p); err != nil {
+				return d, err
+			}
+		default:
+			view, ok, err := readView(fsys, p)
+			if err != nil {
+				return d, err
+			}
+			if ok {
+				d.Views[entry.Name()] = view
+			}
+		}
+	}
+	return d, nil
+}
+
+// parseDesignDocumentFile reads a file at the top level of a design document folder.
+func parseDesignDocumentFile(fsys fs.FS, p string, d *DesignDocument) error {
+	switch path.Base(p) {
+	case fileNameValidateDocUpdate:
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		d.ValidateDocUpdate = string(b)
+	case fileNameOptions:
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &d.Options); err != nil {
+			return fmt.Errorf("couchdb: parsing %s: %w", p, err)
+		}
+	case fileNameMembers:
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(b, &d.Extra); err != nil {
+			return fmt.Errorf("couchdb: parsing %s: %w", p, err)
+		}
+	case fileNameLanguage:
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		d.Language = strings.TrimSpace(string(b))
+	}
+	return nil
+}
+
+// isDir reports whether the entry at p is a folder. Symbolic links are followed.
+func isDir(fsys fs.FS, p string, entry fs.DirEntry) (bool, error) {
+	if entry.Type()&fs.ModeSymlink == 0 {
+		return entry.IsDir(), nil
+	}
+	info, err := fs.Stat(fsys, p)
+	if err != nil {
+		return false, err
+	}
+	return info.IsDir(), nil
+}
+
+// readView reads the map and reduce function of a view folder.
+// ok is false if the folder has no map function. A folder with view files
+// but without map.js is an error as Seed would delete the view.
+func readView(fsys fs.FS, dir string) (view DesignDocumentView, ok bool, err error) {
+	bMap, err := fs.ReadFile(fsys, path.Join(dir, fileNameMap))
+	if errors.Is(err, fs.ErrNotExist) {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return view, false, err
+		}
+		for _, entry := range entries {
+			switch strings.ToLower(entry.Name()) {
+			case fileNameMap, fileNameReduce, fileNameOptions:
+				return view, false, fmt.Errorf("couchdb: view %s has no %s", dir, fileNameMap)
+			}
+		}
+		return view, false, nil
+	}
+	if err != nil {
+		return view, false, err
+	}
+	view.Map = string(bMap)
+	// get reduce function only if it exists
+	bReduce, err := fs.ReadFile(fsys, path.Join(dir, fileNameReduce))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return view, false, err
+	}
+	view.Reduce = string(bReduce)
+	bOptions, err := fs.ReadFile(fsys, path.Join(dir, fileNameOptions))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return view, false, err
+	}
+	if bOptions != nil {
+		if err := json.Unmarshal(bOptions, &view.Options); err != nil {
+			return view, false, fmt.Errorf("couchdb: parsing %s: %w", path.Join(dir, fileNameOptions), err)
+		}
+	}
+	return view, true, nil
+}
+
+// readFunctions reads all JavaScript files of a folder into a map keyed by file name.
+func readFunctions(fsys fs.FS, dir string) (map[string]string, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	functions := map[string]string{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), extJavaScript) {
+			continue
+		}
+		isSubDir, err := isDir(fsys, path.Join(dir, entry.Name()), entry)
+		if err != nil {
+			return nil, err
+		}
+		if isSubDir {
+			continue
+		}
+		b, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		functions[strings.TrimSuffix(entry.Name(), extJavaScript)] = string(b)
+	}
+	return functions, nil
+}
+
+// readModules reads all JavaScript files of a folder and its subfolders
+// into nested maps keyed by folder and file name.
+func readModules(fsys fs.FS, dir string) (map[string]interface{}, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	modules := map[string]interface{}{}
+	for _, entry := range entries {
+		p := path.Join(dir, entry.Name())
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		isSubDir, err := isDir(fsys, p, entry)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case isSubDir:
+			sub, err := readModules(fsys, p)
 			if err != nil {
 				return nil, err
 			}
-			view.Map = string(bMap)
-			// get reduce function only if it exists
-			pathReduce := filepath.Join(dirname, designDocumentName, viewName, fileNameReduce)
-			if _, err := os.Stat(pathReduce); err != nil {
-				// ignore error that file does not exist but return other errors
-				if !os.IsNotExist(err) {
-					return nil, err
-				}
-			} else {
-				bReduce, err := ioutil.ReadFile(pathReduce)
-				if err != nil {
-					return nil, err
-				}
-				view.Reduce = string(bReduce)
+			modules[entry.Name()] = sub
+		case strings.HasSuffix(entry.Name(), extJavaScript):
+			b, err := fs.ReadFile(fsys, p)
+			if err != nil {
+				return nil, err
 			}
-			d.Views[viewName] = view
+			modules[strings.TrimSuffix(entry.Name(), extJavaScript)] = string(b)
 		}
-		docs = append(docs, d)
 	}
-	return docs, nil
+	return modules, nil
+}
+
+// Export writes design documents to dirname in the folder layout read by Parse,
+// e.g. to put design documents edited in Fauxton under version control:
+//
+//	docs, err := db.AllDesignDocs()
+//	err = client.Export("design", docs)
+//
+// Parsing the folder again gives design documents which Seed treats as unchanged.
+// Folders of exported design documents are replaced, other files in dirname are kept.
+// The folder of a design document which cannot be exported is left untouched.
+// Design documents holding Mango indexes and design documents whose name starts
+// with an underscore are skipped.
+func (c *Client) Export(dirname string, docs []DesignDocument) error {
+	for _, doc := range docs {
+		if doc.Language == langQuery || strings.HasPrefix(doc.Name(), "_") {
+			continue
+		}
+		if err := exportName(doc.Name()); err != nil {
+			return err
+		}
+		if err := os.MkdirAll(dirname, 0o755); err != nil {
+			return err
+		}
+		// write into a hidden temporary folder first to keep the old folder on errors
+		tmp, err := os.MkdirTemp(dirname, ".export-")
+		if err != nil {
+			return err
+		}
+		if err := exportDesignDocument(tmp, doc); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+		dir := filepath.Join(dirname, doc.Name())
+		if err := os.RemoveAll(dir); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+		if err := os.Rename(tmp, dir); err != nil {
+			os.RemoveAll(tmp)
+			return err
+		}
+	}
+	return nil
+}
+
+func exportDesignDocument(dir string, doc DesignDocument) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, view := range doc.Views {
+		if err := exportName(name); err != nil {
+			return err
+		}
+		switch name {
+		case dirNameFilters, dirNameShows, dirNameLists, dirNameUpdates, dirNameLib:
+			return fmt.Errorf("couchdb: cannot export view %q of %s", name, doc.ID)
+		}
+		if err := exportView(filepath.Join(dir, name), view); err != nil {
+			return err
+		}
+	}
+	functions := map[string]map[string]string{
+		dirNameFilters: doc.Filters,
+		dirNameShows:   doc.Shows,
+		dirNameLists:   doc.Lists,
+		dirNameUpdates: doc.Updates,
+	}
+	for dirName, fns := range functions {
+		if err := exportFunctions(filepath.Join(dir, dirName), fns); err != nil {
+			return err
+		}
+	}
+	if len(doc.Lib) > 0 {
+		if err := exportModules(filepath.Join(dir, dirNameLib), doc.Lib); err != nil {
+			return err
+		}
+	}
+	if doc.ValidateDocUpdate != "" {
+		if err := os.WriteFile(filepath.Join(dir, fileNameValidateDocUpdate), []byte(doc.ValidateDocUpdate), 0o644); err != nil {
+			return err
+		}
+	}
+	if len(doc.Options) > 0 {
+		if err := exportJSON(filepath.Join(dir, fileNameOptions), doc.Options); err != nil {
+			return err
+		}
+	}
+	if len(doc.Extra) > 0 {
+		if err := exportJSON(filepath.Join(dir, fileNameMembers), doc.Extra); err != nil {
+			return err
+		}
+	}
+	if doc.Language != "" && doc.Language != langJavaScript {
+		if err := os.WriteFile(filepath.Join(dir, fileNameLanguage), []byte(doc.Language+"\n"), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportView writes the map and reduce function and the options of a view.
+func exportView(dir string, view DesignDocumentView) error {
+	if view.Map == "" {
+		return fmt.Errorf("couchdb: cannot export view %s without map function", filepath.Base(dir))
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, fileNameMap), []byte(view.Map), 0o644); err != nil {
+		return err
+	}
+	if view.Reduce != "" {
+		if err := os.WriteFile(filepath.Join(dir, fileNameReduce), []byte(view.Reduce), 0o644); err != nil {
+			return err
+		}
+	}
+	if len(view.Options) > 0 {
+		return exportJSON(filepath.Join(dir, fileNameOptions), view.Options)
+	}
+	return nil
+}
+
+// exportFunctions writes every function into a JavaScript file named after it.
+func exportFunctions(dir string, functions map[string]string) error {
+	if len(functions) == 0 {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, fn := range functions {
+		if err := exportName(name); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, name+extJavaScript), []byte(fn), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportModules writes CommonJS modules into JavaScript files and nested objects into folders.
+func exportModules(dir string, modules map[string]interface{}) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for name, module := range modules {
+		if err := exportName(name); err != nil {
+			return err
+		}
+		switch module := module.(type) {
+		case string:
+			if err := os.WriteFile(filepath.Join(dir, name+extJavaScript), []byte(module), 0o644); err != nil {
+				return err
+			}
+		case map[string]interface{}:
+			if err := exportModules(filepath.Join(dir, name), module); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("couchdb: cannot export module %s of type %T", name, module)
+		}
+	}
+	return nil
+}
+
+func exportJSON(filename string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(b, '\n'), 0o644)
+}
+
+// exportName makes sure a name can be used as file name and is read back by Parse.
+func exportName(name string) error {
+	if name == "" || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("couchdb: cannot export %q as file name", name)
+	}
+	return nil
 }
@@ -0,0 +1,71 @@
+package couchdb
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ClientOption configures a Client created by NewClient or NewAuthClient.
+type ClientOption func(*Client) error
+
+// WithHTTPClient sets the http.Client used for all requests.
+// The client is copied so the cookie jar can be attached without modifying the original.
+// Its transport, and therefore its connection pool, is shared with the original.
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) error {
+		if hc == nil {
+			return errors.New("couchdb: http client must not be nil")
+		}
+		copied := *hc
+		c.HTTPClient = &copied
+		return nil
+	}
+}
+
+// WithTransport sets the http.RoundTripper used for all requests.
+// Use it for proxies, custom dialers or instrumentation.
+func WithTransport(rt http.RoundTripper) ClientOption {
+	return func(c *Client) error {
+		c.HTTPClient.Transport = rt
+		return nil
+	}
+}
+
+// WithTimeout sets the overall timeout of a single request.
+// The timeout includes reading the response body so it also limits
+// long running requests like continuous feeds.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) error {
+		c.HTTPClient.Timeout = d
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration, e.g. custom root CAs or client certificates.
+// It requires the transport to be an *http.Transport, which is the default.
+func WithTLSConfig(config *tls.Config) ClientOption {
+	return func(c *Client) error {
+		var transport *http.Transport
+		switch t := c.HTTPClient.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = t.Clone()
+		default:
+			return errors.New("couchdb: tls config requires an *http.Transport")
+		}
+		transport.TLSClientConfig = config
+		c.HTTPClient.Transport = transport
+		return nil
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(userAgent string) ClientOption {
+	return func(c *Client) error {
+		c.UserAgent = userAgent
+		return nil
+	}
+}
@@ -2,15 +2,24 @@ package couchdb
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/segmentio/pointer"
 )
@@ -49,6 +58,229 @@ func TestInfo(t *testing.T) {
 	}
 }
 
+func TestInfoContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.InfoContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context canceled error but got %v", err)
+	}
+}
+
+func TestNewClientOptions(t *testing.T) {
+	var userAgent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent = r.Header.Get("User-Agent")
+		fmt.Fprint(w, `{"couchdb":"Welcome"}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u,
+		WithTimeout(5*time.Second),
+		WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS12}),
+		WithUserAgent("couchdb-test"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.HTTPClient.Timeout != 5*time.Second {
+		t.Errorf("expected timeout 5s but got %s", c.HTTPClient.Timeout)
+	}
+	if c.HTTPClient.Jar == nil {
+		t.Error("expected cookie jar to be attached to http client")
+	}
+	if _, err := c.Info(); err != nil {
+		t.Fatal(err)
+	}
+	if userAgent != "couchdb-test" {
+		t.Errorf("expected user agent couchdb-test but got %s", userAgent)
+	}
+	db := c.Use("dummy").(*Database)
+	if db.Client.HTTPClient != c.HTTPClient {
+		t.Error("expected database to share the http client")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error":"service_unavailable","reason":"try again"}`)
+			return
+		}
+		fmt.Fprint(w, `{"couchdb":"Welcome"}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	attempts := []RetryAttempt{}
+	c, err := NewClient(u, WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		OnAttempt: func(a RetryAttempt) {
+			attempts = append(attempts, a)
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("get", func(t *testing.T) {
+		info, err := c.Info()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Couchdb != "Welcome" {
+			t.Errorf("expected Welcome got %s", info.Couchdb)
+		}
+		if requests != 3 {
+			t.Errorf("expected 3 requests but got %d", requests)
+		}
+		if len(attempts) != 2 {
+			t.Fatalf("expected 2 failed attempts but got %d", len(attempts))
+		}
+		if attempts[0].StatusCode != http.StatusServiceUnavailable || !attempts[0].Retry {
+			t.Errorf("expected first attempt to be retried after 503 but got %+v", attempts[0])
+		}
+	})
+	t.Run("post", func(t *testing.T) {
+		requests = 0
+		if _, err := c.Request(http.MethodPost, "", strings.NewReader("{}"), "application/json"); err == nil {
+			t.Fatal("expected post to fail")
+		}
+		if requests != 1 {
+			t.Errorf("expected post not to be retried but got %d requests", requests)
+		}
+	})
+	t.Run("override", func(t *testing.T) {
+		requests = 0
+		ctx := ContextWithRetryPolicy(context.Background(), RetryPolicy{})
+		if _, err := c.InfoContext(ctx); err == nil {
+			t.Fatal("expected request to fail")
+		}
+		if requests != 1 {
+			t.Errorf("expected retries to be disabled but got %d requests", requests)
+		}
+	})
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := RetryPolicy{
+		MinBackoff: 100 * time.Millisecond,
+		MaxBackoff: time.Second,
+	}
+	tests := []struct {
+		attempt    int
+		retryAfter string
+		delay      time.Duration
+	}{
+		{1, "", 100 * time.Millisecond},
+		{2, "", 200 * time.Millisecond},
+		{3, "", 400 * time.Millisecond},
+		{5, "", time.Second},
+		{1, "0", 0},
+		{1, "120", time.Second},
+		{1, "invalid", 100 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		if delay := p.backoff(tt.attempt, tt.retryAfter); delay != tt.delay {
+			t.Errorf("backoff(%d, %q): expected %s, actual %s", tt.attempt, tt.retryAfter, tt.delay, delay)
+		}
+	}
+}
+
+func TestErrorIs(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `{"error":"not_found","reason":"missing"}`)
+	}))
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = c.Info()
+	if !errors.Is(err, ErrNotFound) || !IsNotFound(err) {
+		t.Errorf("expected not found error but got %v", err)
+	}
+	if IsConflict(err) || IsNetworkError(err) {
+		t.Errorf("expected only not found error but got %v", err)
+	}
+	var cerr *Error
+	if !errors.As(err, &cerr) || cerr.Reason != "missing" {
+		t.Errorf("expected reason missing but got %v", err)
+	}
+	// no server is listening anymore
+	ts.Close()
+	_, err = c.Info()
+	if !IsNetworkError(err) {
+		t.Errorf("expected network error but got %v", err)
+	}
+	if IsNotFound(err) {
+		t.Errorf("expected network error not to be not found")
+	}
+}
+
+func TestNewError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Couch-Request-ID", "abc123")
+		if r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html")
+		w.WriteHeader(http.StatusBadGateway)
+		fmt.Fprint(w, "<html><body>"+strings.Repeat("bad gateway ", 200)+"</body></html>")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("head", func(t *testing.T) {
+		_, err := c.Use("dummy").Head("missing")
+		var cerr *Error
+		if !errors.As(err, &cerr) {
+			t.Fatalf("expected *Error but got %v", err)
+		}
+		if cerr.StatusCode != http.StatusNotFound || cerr.Type != "not_found" || cerr.Reason != "Not Found" {
+			t.Errorf("expected not found error but got %v", cerr)
+		}
+		if cerr.RequestID != "abc123" {
+			t.Errorf("expected request id abc123 but got %s", cerr.RequestID)
+		}
+	})
+	t.Run("html", func(t *testing.T) {
+		_, err := c.Info()
+		var cerr *Error
+		if !errors.As(err, &cerr) {
+			t.Fatalf("expected *Error but got %v", err)
+		}
+		if cerr.StatusCode != http.StatusBadGateway || cerr.Type != "bad_gateway" {
+			t.Errorf("expected bad gateway error but got %v", cerr)
+		}
+		if len(cerr.Body) != maxErrorBody || !strings.HasPrefix(cerr.Body, "<html>") {
+			t.Errorf("expected truncated html body but got %q", cerr.Body)
+		}
+		if cerr.Header.Get("Content-Type") != "text/html" {
+			t.Errorf("expected content type text/html but got %s", cerr.Header.Get("Content-Type"))
+		}
+	})
+}
+
 func TestActiveTasks(t *testing.T) {
 	res, err := client.ActiveTasks()
 	if err != nil {
@@ -935,6 +1167,28 @@ func TestAllDocs(t *testing.T) {
 	if len(res.Rows) != 3 {
 		t.Errorf("expected length rows equals 3 but got %d", len(res.Rows))
 	}
+	rows, err := db.AllDocsRows(&QueryParameters{IncludeDocs: pointer.Bool(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	count := 0
+	for rows.Next() {
+		var doc DummyDocument
+		if err := rows.ScanDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.ID != rows.ID() || !strings.HasPrefix(doc.Foo, "foo") {
+			t.Errorf("unexpected document %+v", doc)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 3 || rows.TotalRows() != 3 {
+		t.Errorf("expected 3 rows but got %d of %d", count, rows.TotalRows())
+	}
 }
 
 func TestPurge(t *testing.T) {
@@ -1207,7 +1461,7 @@ func TestView(t *testing.T) {
 	t.Run("get with query parameters", func(t *testing.T) {
 		view := db.View("test")
 		params := QueryParameters{
-			Key: pointer.String(fmt.Sprintf("%q", "foo1")),
+			Key: "foo1",
 		}
 		res, err := view.Get("foo", params)
 		if err != nil {
@@ -1221,8 +1475,8 @@ func TestView(t *testing.T) {
 	t.Run("get with start and end key", func(t *testing.T) {
 		view := db.View("test")
 		params := QueryParameters{
-			StartKey: pointer.String(fmt.Sprintf("[%q,%q]", "foo2", "beep2")),
-			EndKey:   pointer.String(fmt.Sprintf("[%q,%q]", "foo2", "beep2")),
+			StartKey: []interface{}{"foo2", "beep2"},
+			EndKey:   []interface{}{"foo2", "beep2"},
 		}
 		res, err := view.Get("complex", params)
 		if err != nil {
@@ -1236,8 +1490,8 @@ func TestView(t *testing.T) {
 	t.Run("get with integer", func(t *testing.T) {
 		view := db.View("test")
 		params := QueryParameters{
-			StartKey: pointer.String(fmt.Sprintf("[%q,%d]", "foo2", 20)),
-			EndKey:   pointer.String(fmt.Sprintf("[%q,%d]", "foo2", 20)),
+			StartKey: []interface{}{"foo2", 20},
+			EndKey:   []interface{}{"foo2", 20},
 		}
 		res, err := view.Get("int", params)
 		if err != nil {
@@ -1264,7 +1518,7 @@ func TestView(t *testing.T) {
 	t.Run("get with reduce and group", func(t *testing.T) {
 		view := db.View("person")
 		params := QueryParameters{
-			Key:        pointer.String(fmt.Sprintf("%q", "female")),
+			Key:        "female",
 			GroupLevel: pointer.Int(1),
 		}
 		res, err := view.Get("ageByGender", params)
@@ -1280,7 +1534,7 @@ func TestView(t *testing.T) {
 	t.Run("get without reduce", func(t *testing.T) {
 		view := db.View("person")
 		params := QueryParameters{
-			Key:    pointer.String(fmt.Sprintf("%q", "male")),
+			Key:    "male",
 			Reduce: pointer.Bool(false),
 		}
 		res, err := view.Get("ageByGender", params)
@@ -1297,7 +1551,7 @@ func TestView(t *testing.T) {
 		params := QueryParameters{
 			Reduce: pointer.Bool(false),
 		}
-		res, err := view.Post("ageByGender", []string{"male"}, params)
+		res, err := view.Post("ageByGender", []interface{}{"male"}, params)
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -1306,6 +1560,23 @@ func TestView(t *testing.T) {
 		}
 	})
 
+	t.Run("queries", func(t *testing.T) {
+		view := db.View("test")
+		results, err := view.Queries("foo", []QueryParameters{
+			{Key: "foo1"},
+			{Limit: pointer.Int(2)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != 2 {
+			t.Fatalf("expected 2 results but got %d", len(results))
+		}
+		if len(results[0].Rows) != 1 || len(results[1].Rows) != 2 {
+			t.Errorf("expected 1 and 2 rows but got %d and %d", len(results[0].Rows), len(results[1].Rows))
+		}
+	})
+
 }
 
 // mimeType()
@@ -1650,25 +1921,25 @@ func TestDiff(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
 			difference := diff(test.cache, test.database)
-			if len(difference.additions) != test.additions {
+			if len(difference.Additions) != test.additions {
 				t.Errorf(
 					"exp %d additions but got %d",
 					test.additions,
-					len(difference.additions),
+					len(difference.Additions),
 				)
 			}
-			if len(difference.changes) != test.changes {
+			if len(difference.Changes) != test.changes {
 				t.Errorf(
 					"exp %d changes but got %d",
 					test.changes,
-					len(difference.changes),
+					len(difference.Changes),
 				)
 			}
-			if len(difference.deletions) != test.deletions {
+			if len(difference.Deletions) != test.deletions {
 				t.Errorf(
 					"exp %d deletions but got %d",
 					test.deletions,
-					len(difference.deletions),
+					len(difference.Deletions),
 				)
 			}
 		})
@@ -1774,6 +2045,14 @@ func TestSeed(t *testing.T) {
 	if err := db.Seed(docs); err != nil {
 		t.Error(err)
 	}
+	// seeding the same design documents again changes nothing
+	plan, err := db.Plan(docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !plan.Empty() {
+		t.Errorf("expected empty plan but got %s", plan)
+	}
 	// simulate player design document has changed on disk
 	changedPlayer := DesignDocument{
 		Document: Document{
@@ -1852,3 +2131,1790 @@ func TestSeed(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestChanges(t *testing.T) {
+	name, err := RandDBName(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Create(name); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(name)
+	db := client.Use(name)
+	for _, id := range []string{"one", "two", "three"} {
+		if _, err := db.Put(&DummyDocument{Document: Document{ID: id}, Foo: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Run("normal", func(t *testing.T) {
+		res, err := db.Changes(&ChangesParameters{IncludeDocs: pointer.Bool(true)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Results) != 3 {
+			t.Fatalf("expected 3 changes but got %d", len(res.Results))
+		}
+		var doc DummyDocument
+		if err := res.Results[0].DecodeDoc(&doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.Foo != doc.ID {
+			t.Errorf("expected foo to be %s but got %s", doc.ID, doc.Foo)
+		}
+	})
+	t.Run("doc ids", func(t *testing.T) {
+		res, err := db.Changes(&ChangesParameters{DocIDs: []string{"two"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Results) != 1 || res.Results[0].ID != "two" {
+			t.Errorf("expected only change for two but got %v", res.Results)
+		}
+	})
+	t.Run("continuous", func(t *testing.T) {
+		feed, err := db.Follow(&ChangesParameters{Heartbeat: pointer.Int(1000)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer feed.Close()
+		ids := []string{}
+		for len(ids) < 3 && feed.Next() {
+			ids = append(ids, feed.Change().ID)
+		}
+		if len(ids) != 3 {
+			t.Fatalf("expected 3 changes but got %v (%v)", ids, feed.Err())
+		}
+		// a new document arrives on the open feed
+		go db.Put(&DummyDocument{Document: Document{ID: "four"}})
+		if !feed.Next() {
+			t.Fatal(feed.Err())
+		}
+		if feed.Change().ID != "four" {
+			t.Errorf("expected change for four but got %s", feed.Change().ID)
+		}
+	})
+}
+
+func TestFollowReconnect(t *testing.T) {
+	sinces := []string{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		since := r.URL.Query().Get("since")
+		sinces = append(sinces, since)
+		switch r.URL.Query().Get("feed") {
+		case FeedEventSource:
+			fmt.Fprint(w, "data: {\"seq\":\"1-a\",\"id\":\"a\",\"changes\":[{\"rev\":\"1-x\"}]}\nid: 1-a\n\n")
+			fmt.Fprint(w, "event: heartbeat\ndata: \n\n")
+		case FeedLongpoll:
+			if since == "" {
+				fmt.Fprint(w, `{"results":[{"seq":1,"id":"a","changes":[{"rev":"1-x"}]}],"last_seq":1,"pending":0}`)
+				return
+			}
+			<-r.Context().Done()
+		default:
+			if since == "" {
+				fmt.Fprint(w, "{\"seq\":\"1-a\",\"id\":\"a\",\"changes\":[{\"rev\":\"1-x\"}]}\n\n")
+				fmt.Fprint(w, "{\"seq\":\"2-b\",\"id\":\"b\",\"changes\":[{\"rev\":\"1-y\"}],\"deleted\":true}\n")
+				fmt.Fprint(w, "{\"last_seq\":\"2-b\",\"pending\":0}\n")
+				return
+			}
+			fmt.Fprint(w, "{\"seq\":\"3-c\",\"id\":\"c\",\"changes\":[{\"rev\":\"1-z\"}]}\n")
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Use("dummy")
+	t.Run("continuous", func(t *testing.T) {
+		sinces = nil
+		feed, err := db.Follow(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for len(ids) < 3 && feed.Next() {
+			ids = append(ids, feed.Change().ID)
+		}
+		if !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+			t.Fatalf("expected changes a, b and c but got %v (%v)", ids, feed.Err())
+		}
+		if !reflect.DeepEqual(sinces, []string{"", "2-b"}) {
+			t.Errorf("expected reconnect since 2-b but got %v", sinces)
+		}
+		if feed.LastSeq() != "3-c" {
+			t.Errorf("expected last seq 3-c but got %s", feed.LastSeq())
+		}
+		feed.Close()
+		if feed.Next() {
+			t.Error("expected closed feed to end")
+		}
+		if feed.Err() != nil {
+			t.Errorf("expected no error after close but got %v", feed.Err())
+		}
+	})
+	t.Run("longpoll", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		feed, err := db.FollowContext(ctx, &ChangesParameters{Feed: pointer.String(FeedLongpoll)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !feed.Next() {
+			t.Fatal(feed.Err())
+		}
+		if feed.Change().Seq != "1" {
+			t.Errorf("expected numeric seq 1 but got %s", feed.Change().Seq)
+		}
+		cancel()
+		if feed.Next() {
+			t.Error("expected cancelled feed to end")
+		}
+		if !errors.Is(feed.Err(), context.Canceled) {
+			t.Errorf("expected context canceled but got %v", feed.Err())
+		}
+	})
+	t.Run("eventsource", func(t *testing.T) {
+		feed, err := db.Follow(&ChangesParameters{Feed: pointer.String(FeedEventSource)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer feed.Close()
+		if !feed.Next() {
+			t.Fatal(feed.Err())
+		}
+		if feed.Change().ID != "a" || feed.Change().Changes[0].Rev != "1-x" {
+			t.Errorf("expected change a with rev 1-x but got %+v", feed.Change())
+		}
+	})
+}
+
+func TestChangesProcessor(t *testing.T) {
+	var checkpoint []byte
+	changes := []string{
+		`{"seq":"1-a","id":"a","changes":[{"rev":"1-a"}]}`,
+		`{"seq":"2-b","id":"b","changes":[{"rev":"1-b"}]}`,
+		`{"seq":"3-c","id":"c","changes":[{"rev":"1-c"}]}`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_changes"):
+			results := changes
+			if since := r.URL.Query().Get("since"); since != "" {
+				n, _ := strconv.Atoi(strings.Split(since, "-")[0])
+				results = changes[n:]
+			}
+			fmt.Fprintf(w, `{"results":[%s],"last_seq":"3-c","pending":0}`, strings.Join(results, ","))
+		case r.URL.EscapedPath() == "/dummy/_local%2Fworker" && r.Method == http.MethodGet:
+			if checkpoint == nil {
+				w.WriteHeader(http.StatusNotFound)
+				fmt.Fprint(w, `{"error":"not_found","reason":"missing"}`)
+				return
+			}
+			w.Write(checkpoint)
+		case r.URL.EscapedPath() == "/dummy/_local%2Fworker" && r.Method == http.MethodPut:
+			checkpoint, _ = io.ReadAll(r.Body)
+			fmt.Fprint(w, `{"ok":true,"id":"_local/worker","rev":"0-1"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL)
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := []string{}
+	failures := 0
+	p := &ChangesProcessor{
+		Database: c.Use("dummy"),
+		Name:     "worker",
+		Params:   ChangesParameters{Feed: pointer.String(FeedNormal)},
+		Handler: func(ctx context.Context, change Change) error {
+			// fail once to test retries
+			if change.ID == "b" && failures == 0 {
+				failures++
+				return errors.New("temporary")
+			}
+			seen = append(seen, change.ID)
+			return nil
+		},
+		Retry: &RetryPolicy{MaxAttempts: 2, MinBackoff: time.Millisecond},
+	}
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, []string{"a", "b", "c"}) {
+		t.Errorf("expected changes a, b and c but got %v", seen)
+	}
+	cp, err := p.Checkpoint(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cp.Seq != "3-c" {
+		t.Errorf("expected checkpoint 3-c but got %s", cp.Seq)
+	}
+	// resume from checkpoint
+	changes = append(changes, `{"seq":"4-d","id":"d","changes":[{"rev":"1-d"}]}`)
+	seen = nil
+	if err := p.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(seen, []string{"d"}) {
+		t.Errorf("expected only change d but got %v", seen)
+	}
+	// handler keeps failing
+	changes = append(changes, `{"seq":"5-e","id":"e","changes":[{"rev":"1-e"}]}`)
+	p.Handler = func(ctx context.Context, change Change) error {
+		return errors.New("permanent")
+	}
+	if err := p.Run(context.Background()); err == nil {
+		t.Error("expected failing handler to stop the processor")
+	}
+	if cp, _ := p.Checkpoint(context.Background()); cp.Seq != "4-d" {
+		t.Errorf("expected checkpoint to stay at 4-d but got %s", cp.Seq)
+	}
+}
+
+func TestFind(t *testing.T) {
+	name, err := RandDBName(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Create(name); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(name)
+	db := client.Use(name)
+	people := []CouchDoc{
+		&Person{Type: "person", Name: "John", Age: 45, Gender: "male"},
+		&Person{Type: "person", Name: "Lily", Age: 20, Gender: "female"},
+		&Person{Type: "person", Name: "Sophia", Age: 66, Gender: "female"},
+		&DataDocument{Type: "data", Foo: "foo"},
+	}
+	if _, err := db.Bulk(people); err != nil {
+		t.Fatal(err)
+	}
+	selector := map[string]interface{}{
+		"type": "person",
+		"age":  map[string]interface{}{"$gt": 30},
+	}
+	var docs []Person
+	res, err := db.Find(FindQuery{
+		Selector:       selector,
+		Fields:         []string{"name", "age"},
+		ExecutionStats: true,
+	}, &docs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 documents but got %d", len(docs))
+	}
+	for _, doc := range docs {
+		if doc.Age <= 30 {
+			t.Errorf("expected age above 30 but got %v", doc.Age)
+		}
+		if doc.Gender != "" {
+			t.Errorf("expected gender not to be selected but got %s", doc.Gender)
+		}
+	}
+	if res.Bookmark == "" {
+		t.Error("expected bookmark")
+	}
+	if res.ExecutionStats == nil || res.ExecutionStats.ResultsReturned != 2 {
+		t.Errorf("expected execution stats with 2 results but got %+v", res.ExecutionStats)
+	}
+	// the next page starts after the bookmark
+	var next []Person
+	if _, err := db.Find(FindQuery{
+		Selector: selector,
+		Bookmark: res.Bookmark,
+	}, &next); err != nil {
+		t.Fatal(err)
+	}
+	if len(next) != 0 {
+		t.Errorf("expected no documents after bookmark but got %d", len(next))
+	}
+}
+
+func TestDesignDocumentViewMango(t *testing.T) {
+	in := `{"_id":"_design/idx","language":"query","views":{"by-age":{"map":{"fields":{"age":"asc"},"partial_filter_selector":{}},"reduce":"_count","options":{"def":{"fields":["age"]}}}}}`
+	var doc DesignDocument
+	if err := json.Unmarshal([]byte(in), &doc); err != nil {
+		t.Fatal(err)
+	}
+	view := doc.Views["by-age"]
+	if view.Map != "" || string(view.MangoMap) != `{"fields":{"age":"asc"},"partial_filter_selector":{}}` {
+		t.Errorf("expected mango map but got %q %s", view.Map, view.MangoMap)
+	}
+	if view.Reduce != "_count" {
+		t.Errorf("expected reduce _count but got %s", view.Reduce)
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected, actual interface{}
+	json.Unmarshal([]byte(in), &expected)
+	json.Unmarshal(out, &actual)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Errorf("expected %s but got %s", in, out)
+	}
+	// javascript views are unchanged
+	var js DesignDocumentView
+	if err := json.Unmarshal([]byte(`{"map":"function(doc) {}"}`), &js); err != nil {
+		t.Fatal(err)
+	}
+	if js.Map != "function(doc) {}" || js.MangoMap != nil {
+		t.Errorf("expected javascript map but got %+v", js)
+	}
+}
+
+func TestIndexes(t *testing.T) {
+	name, err := RandDBName(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Create(name); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(name)
+	db := client.Use(name)
+	index := IndexDefinition{
+		Index: Index{
+			Fields:                []interface{}{"age"},
+			PartialFilterSelector: map[string]interface{}{"type": "person"},
+		},
+		DDoc: "people",
+		Name: "by-age",
+		Type: IndexTypeJSON,
+	}
+	res, err := db.CreateIndex(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != "created" || res.ID != "_design/people" || res.Name != "by-age" {
+		t.Errorf("expected created index _design/people by-age but got %+v", res)
+	}
+	res, err = db.CreateIndex(index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Result != "exists" {
+		t.Errorf("expected index to exist but got %s", res.Result)
+	}
+	indexes, err := db.ListIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 2 {
+		t.Fatalf("expected _all_docs and by-age index but got %+v", indexes)
+	}
+	if indexes[0].Type != IndexTypeSpecial {
+		t.Errorf("expected first index to be special but got %s", indexes[0].Type)
+	}
+	if indexes[1].DDoc != "_design/people" || indexes[1].Name != "by-age" {
+		t.Errorf("expected by-age index but got %+v", indexes[1])
+	}
+	// mango design documents can be read like all others
+	if _, err := db.AllDesignDocs(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.DeleteIndex("people", IndexTypeJSON, "by-age"); err != nil {
+		t.Fatal(err)
+	}
+	indexes, err = db.ListIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 1 {
+		t.Errorf("expected only _all_docs index but got %+v", indexes)
+	}
+}
+
+func TestSeedIndexes(t *testing.T) {
+	name, err := RandDBName(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Create(name); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(name)
+	db := client.Use(name)
+	docs, err := client.Parse(filepath.Join("example", "design"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	byAge := IndexDefinition{Index: Index{Fields: []interface{}{"age"}}, DDoc: "mango", Name: "by-age"}
+	byName := IndexDefinition{Index: Index{Fields: []interface{}{"name"}}, DDoc: "mango", Name: "by-name"}
+	if err := db.Seed(docs, SeedIndexes(byAge, byName)); err != nil {
+		t.Fatal(err)
+	}
+	// seeding again without indexes keeps them
+	if err := db.Seed(docs); err != nil {
+		t.Fatal(err)
+	}
+	indexes, err := db.ListIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 3 {
+		t.Fatalf("expected 3 indexes but got %+v", indexes)
+	}
+	// by-name is no longer declared
+	if err := db.Seed(docs, SeedIndexes(byAge)); err != nil {
+		t.Fatal(err)
+	}
+	indexes, err = db.ListIndexes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(indexes) != 2 || indexes[1].Name != "by-age" {
+		t.Errorf("expected only by-age index but got %+v", indexes)
+	}
+	designDocs, err := db.AllDesignDocs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(designDocs) != 3 {
+		t.Errorf("expected player, user and mango design documents but got %d", len(designDocs))
+	}
+}
+
+func TestExplain(t *testing.T) {
+	name, err := RandDBName(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Create(name); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Delete(name)
+	db := client.Use(name)
+	query := FindQuery{
+		Selector: map[string]interface{}{
+			"age": map[string]interface{}{"$gt": 30},
+		},
+		Limit: pointer.Int(10),
+	}
+	res, err := db.Explain(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.FullScan() {
+		t.Errorf("expected full scan without index but got %+v", res.Index)
+	}
+	if res.Limit != 10 {
+		t.Errorf("expected limit 10 but got %d", res.Limit)
+	}
+	if _, err := db.CreateIndex(IndexDefinition{
+		Index: Index{Fields: []interface{}{"age"}},
+		DDoc:  "people",
+		Name:  "by-age",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	res, err = db.Explain(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.FullScan() {
+		t.Error("expected query to use an index")
+	}
+	if res.Index.Name != "by-age" {
+		t.Errorf("expected by-age index but got %s", res.Index.Name)
+	}
+	if res.MRArgs == nil {
+		t.Error("expected view query arguments")
+	}
+}
+
+func TestCollate(t *testing.T) {
+	// ordered like in http://docs.couchdb.org/en/latest/ddocs/views/collation.html
+	keys := []interface{}{
+		nil,
+		false,
+		true,
+		-1.5,
+		json.Number("1"),
+		2,
+		3.0,
+		json.Number("4"),
+		"_",
+		"-",
+		"$",
+		"0",
+		"10",
+		"9",
+		"a",
+		"A",
+		"á",
+		"aa",
+		"ab",
+		"b",
+		"B",
+		"ba",
+		"bb",
+		"z",
+		"α",
+		HighString,
+		[]interface{}{"a"},
+		[]interface{}{"b"},
+		[]interface{}{"b", "c"},
+		[]interface{}{"b", "c", "a"},
+		[]interface{}{"b", "d"},
+		[]interface{}{"b", "d", "e"},
+		[]interface{}{"b", "d", HighKey},
+		map[string]interface{}{"a": 1},
+		map[string]interface{}{"a": 2},
+		map[string]interface{}{"b": 1},
+		map[string]interface{}{"b": 2},
+		json.RawMessage(`{"b": 2, "a": 1}`),
+		map[string]interface{}{"b": 2, "c": 2},
+	}
+	for i := range keys {
+		for j := range keys {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			if c := Collate(keys[i], keys[j]); c != expected {
+				t.Errorf("collate %v and %v: expected %d but got %d", keys[i], keys[j], expected, c)
+			}
+		}
+	}
+	if Collate(1, 1.0) != 0 {
+		t.Error("expected integers and floats to be equal")
+	}
+	if Collate(json.RawMessage(`[1, {"a": "b"}]`), []interface{}{1.0, map[string]string{"a": "b"}}) != 0 {
+		t.Error("expected raw and decoded JSON to be equal")
+	}
+	start, end := PrefixRange("player", 2017)
+	if Collate(start, []interface{}{"player", 2017, "x"}) >= 0 || Collate(end, []interface{}{"player", 2017, "x"}) <= 0 {
+		t.Errorf("expected range %v to %v to contain prefixed keys", start, end)
+	}
+	startString, endString := StringPrefixRange("ab")
+	if Collate(startString, "abc") >= 0 || Collate(endString, "abzzz") <= 0 {
+		t.Errorf("expected range %s to %s to contain prefixed strings", startString, endString)
+	}
+}
+
+func TestQueryParametersValues(t *testing.T) {
+	params := QueryParameters{
+		Key:         json.RawMessage("null"),
+		Keys:        []interface{}{"a", 1, []interface{}{"b", 2}},
+		StartKey:    []interface{}{"player", 2017},
+		EndKey:      []interface{}{"player", 2017, HighKey},
+		EndKeyDocID: pointer.String("doc"),
+		IncludeDocs: pointer.Bool(true),
+		Conflicts:   pointer.Bool(true),
+		Stable:      pointer.Bool(true),
+		Update:      pointer.String("lazy"),
+		Sorted:      pointer.Bool(false),
+	}
+	q, err := params.values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := url.Values{
+		"key":          {"null"},
+		"keys":         {`["a",1,["b",2]]`},
+		"startkey":     {`["player",2017]`},
+		"endkey":       {`["player",2017,{}]`},
+		"endkey_docid": {"doc"},
+		"include_docs": {"true"},
+		"conflicts":    {"true"},
+		"stable":       {"true"},
+		"update":       {"lazy"},
+		"sorted":       {"false"},
+	}
+	if !reflect.DeepEqual(q, expected) {
+		t.Errorf("expected %v but got %v", expected, q)
+	}
+	legacy := QueryParameters{
+		Key:      pointer.String(`"foo"`),
+		StartKey: (*string)(nil),
+		EndKey:   (*int)(nil),
+	}
+	q, err = legacy.values()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(q, url.Values{"key": {`"foo"`}}) {
+		t.Errorf("expected pre-encoded key and no nil keys but got %v", q)
+	}
+	b, err := json.Marshal(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"key":"foo"}` {
+		t.Errorf("expected pre-encoded key and no nil keys but got %s", b)
+	}
+	var nilParams *QueryParameters
+	if q, err := nilParams.values(); err != nil || len(q) != 0 {
+		t.Errorf("expected empty query but got %v, %v", q, err)
+	}
+	var aliases QueryParameters
+	data := `{"start_key": ["a"], "end_key": ["a", {}], "start_key_doc_id": "x", "limit": 2}`
+	if err := json.Unmarshal([]byte(data), &aliases); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(aliases.StartKey, []interface{}{"a"}) || aliases.EndKey == nil ||
+		*aliases.StartKeyDocID != "x" || *aliases.Limit != 2 {
+		t.Errorf("unexpected parameters %+v", aliases)
+	}
+}
+
+func TestRows(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"total_rows":3,"offset":1,"rows":[`)
+		fmt.Fprint(w, `{"id":"a","key":["player",21],"value":1,"doc":{"_id":"a","name":"john"}},`)
+		fmt.Fprint(w, `{"id":"b","key":["player",22],"value":2,"doc":{"_id":"b","name":"jane"}},`)
+		fmt.Fprint(w, `{"key":"c","error":"not_found"}`)
+		fmt.Fprint(w, `],"update_seq":"5-abc"}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Run("all", func(t *testing.T) {
+		rows, err := c.Use("dummy").View("player").Rows("byAge", QueryParameters{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		if rows.TotalRows() != 3 || rows.Offset() != 1 {
+			t.Errorf("expected total rows and offset before first row but got %d and %d", rows.TotalRows(), rows.Offset())
+		}
+		var ids []string
+		for rows.Next() {
+			if rows.RowError() != "" {
+				if rows.RowError() != "not_found" {
+					t.Errorf("expected not_found but got %s", rows.RowError())
+				}
+				continue
+			}
+			var key []interface{}
+			if err := rows.ScanKey(&key); err != nil {
+				t.Fatal(err)
+			}
+			var value float64
+			if err := rows.ScanValue(&value); err != nil {
+				t.Fatal(err)
+			}
+			var doc struct {
+				ID   string `json:"_id"`
+				Name string `json:"name"`
+			}
+			if err := rows.ScanDoc(&doc); err != nil {
+				t.Fatal(err)
+			}
+			if doc.ID != rows.ID() || key[0] != "player" || value != key[1].(float64)-20 {
+				t.Errorf("unexpected row %v %v %v", key, value, doc)
+			}
+			row, err := rows.Row()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if row.Doc["name"] != doc.Name {
+				t.Errorf("expected generic doc %v to equal %v", row.Doc, doc)
+			}
+			ids = append(ids, rows.ID())
+		}
+		if err := rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(ids, []string{"a", "b"}) {
+			t.Errorf("expected ids a and b but got %v", ids)
+		}
+		if rows.UpdateSeq() != "5-abc" {
+			t.Errorf("expected update seq after last row but got %s", rows.UpdateSeq())
+		}
+	})
+	t.Run("close early", func(t *testing.T) {
+		rows, err := c.Use("dummy").AllDocsRows(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !rows.Next() {
+			t.Fatal("expected first row")
+		}
+		if err := rows.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if rows.Next() {
+			t.Error("expected no rows after close")
+		}
+		if err := rows.ScanDoc(&struct{}{}); err != nil {
+			t.Errorf("expected current row to stay available but got %v", err)
+		}
+	})
+}
+
+func TestPaginator(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+	var findQueries []FindQuery
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if strings.HasSuffix(r.URL.Path, "/_find") {
+			var query FindQuery
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+				t.Error(err)
+				return
+			}
+			findQueries = append(findQueries, query)
+			start := 0
+			if query.Bookmark != "" {
+				start, _ = strconv.Atoi(query.Bookmark)
+			}
+			if query.Skip != nil {
+				start += *query.Skip
+			}
+			end := start + *query.Limit
+			if end > len(ids) {
+				end = len(ids)
+			}
+			docs := []map[string]string{}
+			for _, id := range ids[start:end] {
+				docs = append(docs, map[string]string{"_id": id})
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"docs":     docs,
+				"bookmark": strconv.Itoa(end),
+			})
+			return
+		}
+		start := 0
+		if key := q.Get("startkey"); key != "" {
+			var id string
+			if err := json.Unmarshal([]byte(key), &id); err != nil {
+				t.Error(err)
+				return
+			}
+			for start < len(ids) && ids[start] < id {
+				start++
+			}
+		}
+		if skip := q.Get("skip"); skip != "" {
+			n, _ := strconv.Atoi(skip)
+			start += n
+		}
+		limit, _ := strconv.Atoi(q.Get("limit"))
+		rows := []Row{}
+		for i := start; i < len(ids) && len(rows) < limit; i++ {
+			rows = append(rows, Row{ID: ids[i], Key: ids[i], Value: i})
+		}
+		json.NewEncoder(w).Encode(ViewResponse{TotalRows: len(ids), Rows: rows})
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Use("dummy")
+
+	t.Run("all docs", func(t *testing.T) {
+		p := NewAllDocsPaginator(db, QueryParameters{}, 2)
+		var pages [][]string
+		for p.HasMore() {
+			var rows []Row
+			if err := p.Next(&rows); err != nil {
+				t.Fatal(err)
+			}
+			var page []string
+			for _, row := range rows {
+				page = append(page, row.ID)
+			}
+			pages = append(pages, page)
+		}
+		expected := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}
+		if !reflect.DeepEqual(pages, expected) {
+			t.Errorf("expected %v but got %v", expected, pages)
+		}
+		if err := p.Next(&[]Row{}); err != ErrNoMorePages {
+			t.Errorf("expected ErrNoMorePages but got %v", err)
+		}
+		if p.Cursor() != "" {
+			t.Errorf("expected empty cursor after last page but got %s", p.Cursor())
+		}
+	})
+
+	t.Run("view with cursor", func(t *testing.T) {
+		p := NewViewPaginator(db.View("test"), "foo", QueryParameters{Skip: pointer.Int(1)}, 2)
+		var rows []struct {
+			ID    string `json:"id"`
+			Value int    `json:"value"`
+		}
+		if err := p.Next(&rows); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 || rows[0].ID != "b" || rows[1].Value != 2 {
+			t.Errorf("unexpected first page %v", rows)
+		}
+		cursor := p.Cursor()
+		if cursor == "" {
+			t.Fatal("expected cursor")
+		}
+		resumed := NewViewPaginator(db.View("test"), "foo", QueryParameters{Skip: pointer.Int(1)}, 2)
+		if err := resumed.Resume(cursor); err != nil {
+			t.Fatal(err)
+		}
+		if err := resumed.Next(&rows); err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 2 || rows[0].ID != "d" || rows[1].ID != "e" || resumed.HasMore() {
+			t.Errorf("unexpected resumed page %v", rows)
+		}
+		if err := resumed.Resume("not a cursor"); err == nil {
+			t.Error("expected invalid cursor error")
+		}
+	})
+
+	t.Run("find", func(t *testing.T) {
+		findQueries = nil
+		p := NewFindPaginator(db, FindQuery{Skip: pointer.Int(1)}, 2)
+		var all []string
+		for p.HasMore() {
+			var docs []Document
+			if err := p.Next(&docs); err != nil {
+				t.Fatal(err)
+			}
+			for _, doc := range docs {
+				all = append(all, doc.ID)
+			}
+		}
+		if !reflect.DeepEqual(all, ids[1:]) {
+			t.Errorf("expected %v but got %v", ids[1:], all)
+		}
+		if len(findQueries) < 2 {
+			t.Fatalf("expected at least 2 queries but got %d", len(findQueries))
+		}
+		if second := findQueries[1]; second.Bookmark == "" || second.Skip != nil {
+			t.Errorf("expected bookmark without skip on second page but got %+v", second)
+		}
+	})
+}
+
+func TestQueryView(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_all_docs") {
+			fmt.Fprint(w, `{"total_rows":2,"offset":0,"rows":[`)
+			fmt.Fprint(w, `{"id":"a","key":"a","value":{"rev":"1-x"},"doc":{"_id":"a","_rev":"1-x","foo":"bar"}},`)
+			fmt.Fprint(w, `{"key":"b","error":"not_found"}]}`)
+			return
+		}
+		fmt.Fprint(w, `{"total_rows":1,"offset":0,"update_seq":"7-xyz","rows":[`)
+		fmt.Fprint(w, `{"id":"a","key":["player",12345678901234567890],"value":{"score":1.5}}]}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Use("dummy")
+	type score struct {
+		Score float64 `json:"score"`
+	}
+	res, err := QueryView[[]interface{}, score, DummyDocument](db.View("player"), "byScore", QueryParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 1 || res.TotalRows != 1 || res.UpdateSeq != "7-xyz" {
+		t.Fatalf("unexpected response %+v", res)
+	}
+	row := res.Rows[0]
+	if row.Key[1] != json.Number("12345678901234567890") {
+		t.Errorf("expected number with full precision but got %v", row.Key[1])
+	}
+	if row.Value.Score != 1.5 || row.Doc != nil {
+		t.Errorf("unexpected row %+v", row)
+	}
+	docs, err := QueryAllDocs[DummyDocument](db, &QueryParameters{IncludeDocs: pointer.Bool(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs.Rows) != 2 {
+		t.Fatalf("expected 2 rows but got %d", len(docs.Rows))
+	}
+	if doc := docs.Rows[0].Doc; doc == nil || doc.Foo != "bar" || docs.Rows[0].Value.Rev != "1-x" {
+		t.Errorf("unexpected row %+v", docs.Rows[0])
+	}
+	if docs.Rows[1].Error != "not_found" || docs.Rows[1].Doc != nil {
+		t.Errorf("expected missing document but got %+v", docs.Rows[1])
+	}
+}
+
+func TestQueries(t *testing.T) {
+	var paths []string
+	var bodies []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		paths = append(paths, r.URL.Path)
+		bodies = append(bodies, strings.TrimSpace(string(b)))
+		fmt.Fprint(w, `{"results":[{"total_rows":2,"offset":0,"rows":[{"id":"a","key":"a","value":1}]},{"total_rows":2,"offset":1,"rows":[]}]}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Use("dummy")
+	queries := []QueryParameters{
+		{Keys: []interface{}{"a", []interface{}{"b", 1}}},
+		{StartKey: []interface{}{"b"}, EndKey: []interface{}{"b", HighKey}, Limit: pointer.Int(10)},
+	}
+	results, err := db.View("player").Queries("byAge", queries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || len(results[0].Rows) != 1 || results[1].Offset != 1 {
+		t.Errorf("unexpected results %+v", results)
+	}
+	if _, err := db.AllDocsQueries(queries); err != nil {
+		t.Fatal(err)
+	}
+	expectedPaths := []string{"/dummy/_design/player/_view/byAge/queries", "/dummy/_all_docs/queries"}
+	if !reflect.DeepEqual(paths, expectedPaths) {
+		t.Errorf("expected paths %v but got %v", expectedPaths, paths)
+	}
+	expected := `{"queries":[{"keys":["a",["b",1]]},{"limit":10,"endkey":["b",{}],"startkey":["b"]}]}`
+	for _, body := range bodies {
+		if body != expected {
+			t.Errorf("expected body %s but got %s", expected, body)
+		}
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.EscapedPath(), r.URL.RawQuery, b))
+		w.Header().Set("X-Handler", "yes")
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "hello")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := c.Use("dummy").View("app")
+	res, err := view.Show("page", "doc 1", url.Values{"format": {"html"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusCreated || res.Header.Get("X-Handler") != "yes" || string(res.Body) != "hello" {
+		t.Errorf("unexpected response %+v", res)
+	}
+	if _, err := view.Show("page", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	params := QueryParameters{
+		StartKey: []interface{}{"a"},
+		Params:   url.Values{"format": {"csv"}},
+	}
+	if _, err := view.List("table", "other/byName", params); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := view.Update("bump", "doc1", url.Values{"by": {"2"}}, strings.NewReader(`{"a":1}`), "application/json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := view.Update("create", "", nil, strings.NewReader("x"), "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"GET /dummy/_design/app/_show/page/doc%201 format=html ",
+		"GET /dummy/_design/app/_show/page  ",
+		"GET /dummy/_design/app/_list/table/other/byName format=csv&startkey=%5B%22a%22%5D ",
+		`POST /dummy/_design/app/_update/bump/doc1 by=2 {"a":1}`,
+		"POST /dummy/_design/app/_update/create  x",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestUpdateHandlerNotRetried(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if count == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, `{"error": "unavailable", "reason": "try again"}`)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var attempts []RetryAttempt
+	ctx := ContextWithRetryPolicy(context.Background(), RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		Methods:     []string{http.MethodPost, http.MethodPut},
+		OnAttempt: func(a RetryAttempt) {
+			attempts = append(attempts, a)
+		},
+	})
+	res, err := c.Use("dummy").View("app").UpdateContext(ctx, "bump", "doc1", nil, strings.NewReader(`{"a":1}`), "application/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status 503 but got %d", res.StatusCode)
+	}
+	if count != 1 {
+		t.Errorf("expected exactly 1 request but got %d", count)
+	}
+	if len(attempts) != 1 || attempts[0].Retry {
+		t.Errorf("expected one reported attempt without retry but got %+v", attempts)
+	}
+}
+
+func TestHandlerStatusCodes(t *testing.T) {
+	var count int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch {
+		case strings.Contains(r.URL.Path, "/_show/"):
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, "no such page")
+		case strings.Contains(r.URL.Path, "/_update/"):
+			w.WriteHeader(http.StatusConflict)
+			fmt.Fprint(w, "conflict")
+		default:
+			// a transient failure of a show is retried
+			if count == 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, "rows")
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u, WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 2,
+		MinBackoff:  time.Millisecond,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	view := c.Use("dummy").View("app")
+	res, err := view.Show("page", "doc1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusNotFound || string(res.Body) != "no such page" {
+		t.Errorf("unexpected show response %+v", res)
+	}
+	res, err = view.Update("bump", "doc1", nil, strings.NewReader("x"), "text/plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusConflict || string(res.Body) != "conflict" {
+		t.Errorf("unexpected update response %+v", res)
+	}
+	res, err = view.List("table", "byName", QueryParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.StatusCode != http.StatusOK || string(res.Body) != "rows" || count != 4 {
+		t.Errorf("expected retried list response but got %+v after %d requests", res, count)
+	}
+}
+
+func TestParseFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md":                   {Data: []byte("ignored")},
+		"app/byName/map.js":           {Data: []byte("function(doc){emit(doc.name)}")},
+		"app/byName/reduce.js":        {Data: []byte("_count")},
+		"app/notes/README.md":         {Data: []byte("ignored")},
+		"app/.DS_Store":               {Data: []byte("ignored")},
+		"app/filters/active.js":       {Data: []byte("function(doc,req){return doc.active}")},
+		"app/filters/notes.txt":       {Data: []byte("ignored")},
+		"app/shows/profile.js":        {Data: []byte("function(doc,req){return doc.name}")},
+		"app/lists/csv.js":            {Data: []byte("function(head,req){}")},
+		"app/updates/touch.js":        {Data: []byte("function(doc,req){return [doc,'ok']}")},
+		"app/lib/strings.js":          {Data: []byte("exports.upper=function(s){return s.toUpperCase()}")},
+		"app/lib/validation/email.js": {Data: []byte("exports.valid=function(s){return true}")},
+		"app/validate_doc_update.js":  {Data: []byte("function(newDoc,oldDoc,userCtx){}")},
+		"app/options.json":            {Data: []byte(`{"partitioned": false}`)},
+		"app/language":                {Data: []byte("javascript\n")},
+		"erlang/language":             {Data: []byte("erlang")},
+		"erlang/all/map.js":           {Data: []byte("fun({Doc}) -> Emit(null, null) end.")},
+	}
+	docs, err := client.ParseFS(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 design documents but got %d", len(docs))
+	}
+	expected := DesignDocument{
+		Document: Document{ID: "_design/app"},
+		Language: "javascript",
+		Views: map[string]DesignDocumentView{
+			"byName": {Map: "function(doc){emit(doc.name)}", Reduce: "_count"},
+		},
+		Filters:           map[string]string{"active": "function(doc,req){return doc.active}"},
+		Shows:             map[string]string{"profile": "function(doc,req){return doc.name}"},
+		Lists:             map[string]string{"csv": "function(head,req){}"},
+		Updates:           map[string]string{"touch": "function(doc,req){return [doc,'ok']}"},
+		ValidateDocUpdate: "function(newDoc,oldDoc,userCtx){}",
+		Options:           map[string]interface{}{"partitioned": false},
+		Lib: map[string]interface{}{
+			"strings": "exports.upper=function(s){return s.toUpperCase()}",
+			"validation": map[string]interface{}{
+				"email": "exports.valid=function(s){return true}",
+			},
+		},
+	}
+	if !reflect.DeepEqual(docs[0], expected) {
+		t.Errorf("expected %+v but got %+v", expected, docs[0])
+	}
+	if docs[1].Language != "erlang" || docs[1].Views["all"].Map == "" {
+		t.Errorf("unexpected erlang design document %+v", docs[1])
+	}
+	fsys["broken/options.json"] = &fstest.MapFile{Data: []byte("{")}
+	if _, err := client.ParseFS(fsys); err == nil {
+		t.Error("expected error for invalid options.json")
+	}
+	delete(fsys, "broken/options.json")
+	for _, name := range []string{"Map.js", "reduce.js"} {
+		fsys["app/typo/"+name] = &fstest.MapFile{Data: []byte("function(doc){}")}
+		if _, err := client.ParseFS(fsys); err == nil {
+			t.Errorf("expected error for view with %s but without map.js", name)
+		}
+		delete(fsys, "app/typo/"+name)
+	}
+}
+
+func TestSeedPlan(t *testing.T) {
+	view := map[string]DesignDocumentView{
+		"byName": {Map: "function(doc) {}"},
+	}
+	database := []DesignDocument{
+		{
+			Document: Document{ID: "_design/player", Rev: "1-abc"},
+			Language: langJavaScript,
+			Views:    view,
+			Filters:  map[string]string{"active": "function(doc) {}"},
+		},
+		{
+			Document: Document{ID: "_design/user", Rev: "1-abc"},
+			Language: langJavaScript,
+			Views:    view,
+		},
+		{
+			Document: Document{ID: "_design/same", Rev: "1-abc"},
+			Language: langJavaScript,
+			Views:    view,
+		},
+		{
+			Document: Document{ID: "_design/mango", Rev: "1-abc"},
+			Language: langQuery,
+		},
+	}
+	cache := []DesignDocument{
+		{
+			Document: Document{ID: "_design/player"},
+			Language: "erlang",
+			Views:    view,
+			Filters:  map[string]string{"active": "function(doc) { return true }"},
+			Shows:    map[string]string{"profile": "function(doc) {}"},
+		},
+		{
+			// an empty language equals javascript
+			Document: Document{ID: "_design/same"},
+			Views:    view,
+		},
+		{
+			Document: Document{ID: "_design/car"},
+			Views:    view,
+		},
+	}
+	plan := diff(cache, database)
+	plan.Database = "players"
+	if len(plan.Changes) != 1 {
+		t.Fatalf("expected 1 change but got %d", len(plan.Changes))
+	}
+	expectedFields := []string{"filters.active", "language", "shows"}
+	if !reflect.DeepEqual(plan.Changes[0].Fields, expectedFields) {
+		t.Errorf("expected changed fields %v but got %v", expectedFields, plan.Changes[0].Fields)
+	}
+	if plan.Changes[0].Old.Rev != "1-abc" {
+		t.Errorf("expected old revision but got %s", plan.Changes[0].Old.Rev)
+	}
+	expected := `players: 1 to add, 1 to change, 1 to delete
+  + _design/car
+  ~ _design/player (filters.active, language, shows)
+  - _design/user
+`
+	if plan.String() != expected {
+		t.Errorf("expected report\n%s\nbut got\n%s", expected, plan.String())
+	}
+	empty := diff(database[2:3], database[2:3])
+	if !empty.Empty() || empty.String() != "no changes\n" {
+		t.Errorf("expected empty plan but got %s", empty.String())
+	}
+	// members which DesignDocument does not model are compared as stored
+	stored := DesignDocument{Document: Document{ID: "_design/same", Rev: "2-abc"}}
+	stored.stored = json.RawMessage(`{
+		"_id": "_design/same", "_rev": "2-abc", "filters": {},
+		"views": {"byName": {"map": "function(doc) {}", "reduce": ""}},
+		"rewrites": [{"from": "/", "to": "index.html"}]
+	}`)
+	rewrites := diff(database[2:3], []DesignDocument{stored})
+	if len(rewrites.Changes) != 1 || !reflect.DeepEqual(rewrites.Changes[0].Fields, []string{"rewrites"}) {
+		t.Errorf("expected rewrites to change but got %s", rewrites.String())
+	}
+}
+
+func TestSeedStaged(t *testing.T) {
+	defer func(d time.Duration) { stagedPollInterval = d }(stagedPollInterval)
+	stagedPollInterval = 5 * time.Millisecond
+	var mu sync.Mutex
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_active_tasks" {
+			fmt.Fprint(w, `[
+				{"type": "indexer", "database": "shards/00000000-7fffffff/dummy.1234567890", "design_document": "_design/player_staging", "changes_done": 10, "total_changes": 40},
+				{"type": "indexer", "database": "shards/80000000-ffffffff/dummy.1234567890", "design_document": "_design/player_staging", "changes_done": 5, "total_changes": 60},
+				{"type": "indexer", "database": "shards/00000000-7fffffff/other.1234567890", "design_document": "_design/player_staging", "changes_done": 1, "total_changes": 1}
+			]`)
+			return
+		}
+		mu.Lock()
+		requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Destination")))
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/dummy/_all_docs":
+			fmt.Fprint(w, `{"total_rows": 1, "offset": 0, "rows": [
+				{"id": "_design/player", "key": "_design/player", "value": {"rev": "1-a"},
+				 "doc": {"_id": "_design/player", "_rev": "1-a", "language": "javascript", "views": {"byName": {"map": "old"}}}}
+			]}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/dummy/_design/player_staging":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "not_found", "reason": "missing"}`)
+		case r.Method == http.MethodPut:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"ok": true, "id": "_design/player_staging", "rev": "1-s"}`)
+		case strings.Contains(r.URL.Path, "/_view/"):
+			time.Sleep(30 * time.Millisecond)
+			fmt.Fprint(w, `{"total_rows": 0, "offset": 0, "rows": []}`)
+		default:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"ok": true}`)
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []DesignDocument{
+		{
+			Document: Document{ID: "_design/player"},
+			Language: "javascript",
+			Views: map[string]DesignDocumentView{
+				"byName": {Map: "new"},
+				"byAge":  {Map: "age"},
+			},
+		},
+	}
+	var progress []StagedProgress
+	if err := c.Use("dummy").Seed(docs, SeedStaged(func(p StagedProgress) {
+		progress = append(progress, p)
+	})); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"GET /dummy/_all_docs endkey=%22_design0%22&include_docs=true&startkey=%22_design%2F%22 ",
+		"GET /dummy/_design/player_staging  ",
+		"PUT /dummy/_design/player_staging  ",
+		"GET /dummy/_design/player_staging/_view/byAge limit=0 ",
+		"GET /dummy/_design/player_staging/_view/byName limit=0 ",
+		"COPY /dummy/_design/player_staging  _design/player?rev=1-a",
+		"DELETE /dummy/_design/player_staging rev=1-s ",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+	stages := []string{}
+	indexed := false
+	for _, p := range progress {
+		if p.Database != "dummy" || p.DesignDocument != "_design/player" {
+			t.Errorf("unexpected database %q or design document %q", p.Database, p.DesignDocument)
+		}
+		if p.Stage == StageIndex && p.ChangesDone > 0 {
+			indexed = true
+			if p.ChangesDone != 15 || p.TotalChanges != 100 {
+				t.Errorf("expected 15 of 100 changes but got %d of %d", p.ChangesDone, p.TotalChanges)
+			}
+			continue
+		}
+		stages = append(stages, p.Stage+" "+p.View)
+	}
+	if !indexed {
+		t.Error("expected indexer progress")
+	}
+	expectedStages := []string{"upload ", "index byAge", "index byName", "copy ", "cleanup "}
+	if !reflect.DeepEqual(stages, expectedStages) {
+		t.Errorf("expected stages %v but got %v", expectedStages, stages)
+	}
+}
+
+func TestExport(t *testing.T) {
+	docs := []DesignDocument{
+		{
+			Document: Document{ID: "_design/player", Rev: "3-abc"},
+			Views: map[string]DesignDocumentView{
+				"byName": {Map: "function(doc){emit(doc.name)}"},
+				"byAge": {
+					Map:     "function(doc){emit(doc.age)}",
+					Reduce:  "_count",
+					Options: map[string]interface{}{"collation": "raw"},
+				},
+			},
+			Filters:           map[string]string{"active": "function(doc,req){return doc.active}"},
+			Shows:             map[string]string{"profile": "function(doc,req){return doc.name}"},
+			Lists:             map[string]string{"csv": "function(head,req){}"},
+			Updates:           map[string]string{"touch": "function(doc,req){return [doc,'ok']}"},
+			ValidateDocUpdate: "function(newDoc,oldDoc,userCtx){}",
+			Options:           map[string]interface{}{"partitioned": false},
+			Lib: map[string]interface{}{
+				"strings": "exports.upper=function(s){return s.toUpperCase()}",
+				"validation": map[string]interface{}{
+					"email": "exports.valid=function(s){return true}",
+				},
+			},
+		},
+		{
+			Document: Document{ID: "_design/stats", Rev: "1-abc"},
+			Language: "erlang",
+			Views: map[string]DesignDocumentView{
+				"all": {Map: "fun({Doc}) -> Emit(null, null) end."},
+			},
+		},
+		{
+			Document: Document{ID: "_design/mango", Rev: "1-abc"},
+			Language: langQuery,
+		},
+	}
+	// design documents read by AllDesignDocs are compared as stored,
+	// including members which DesignDocument does not model
+	stored := json.RawMessage(`{
+		"_id": "_design/stored", "_rev": "2-abc", "language": "javascript",
+		"views": {"all": {"map": "function(doc){emit(null)}"}},
+		"autoupdate": false,
+		"rewrites": [{"from": "/", "to": "index.html"}]
+	}`)
+	var fromDB DesignDocument
+	if err := decodeNumbers(stored, &fromDB); err != nil {
+		t.Fatal(err)
+	}
+	fromDB.stored = stored
+	docs = append(docs, fromDB)
+	dir := t.TempDir()
+	// stale views of exported design documents are removed
+	if err := os.MkdirAll(filepath.Join(dir, "player", "removed"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "player", "removed", "map.js"), []byte("function(doc){}"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Export(dir, docs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "mango")); !os.IsNotExist(err) {
+		t.Errorf("expected Mango design document to be skipped but got %v", err)
+	}
+	parsed, err := client.Parse(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 3 {
+		t.Fatalf("expected 3 design documents but got %d", len(parsed))
+	}
+	if plan := diff(parsed, docs); !plan.Empty() {
+		t.Errorf("expected empty plan but got %s", plan.String())
+	}
+	invalid := []DesignDocument{
+		{
+			Document: Document{ID: "_design/player"},
+			Views:    map[string]DesignDocumentView{"lib": {Map: "function(doc){}"}},
+		},
+	}
+	if err := client.Export(dir, invalid); err == nil {
+		t.Error("expected error for view named lib")
+	}
+	// the existing folder survives the failed export
+	kept, err := client.Parse(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan := diff(kept, docs); !plan.Empty() {
+		t.Errorf("expected existing folder to be kept but got %s", plan.String())
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			t.Errorf("expected temporary folder %s to be removed", entry.Name())
+		}
+	}
+}
+
+func TestSeedAll(t *testing.T) {
+	var mu sync.Mutex
+	var puts []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/_all_dbs":
+			fmt.Fprint(w, `["_users", "other", "tenant_a", "tenant_b", "tenant_c"]`)
+		case r.URL.Path == "/tenant_a/_all_docs":
+			fmt.Fprint(w, `{"total_rows": 1, "offset": 0, "rows": [
+				{"id": "_design/player", "key": "_design/player", "value": {"rev": "1-a"},
+				 "doc": {"_id": "_design/player", "_rev": "1-a", "language": "javascript", "views": {"byName": {"map": "function(doc){}"}}}}
+			]}`)
+		case r.URL.Path == "/tenant_b/_all_docs" || r.URL.Path == "/other/_all_docs":
+			fmt.Fprint(w, `{"total_rows": 0, "offset": 0, "rows": []}`)
+		case r.URL.Path == "/tenant_c/_all_docs":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"error": "forbidden", "reason": "no access"}`)
+		case r.URL.Path == "/_active_tasks":
+			fmt.Fprint(w, `[]`)
+		case strings.HasSuffix(r.URL.Path, "_staging") && r.Method == http.MethodGet:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error": "not_found", "reason": "missing"}`)
+		case strings.Contains(r.URL.Path, "/_view/"):
+			fmt.Fprint(w, `{"total_rows": 0, "offset": 0, "rows": []}`)
+		case r.Method == "COPY" || r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"ok": true}`)
+		case r.Method == http.MethodPut:
+			mu.Lock()
+			puts = append(puts, r.URL.Path)
+			mu.Unlock()
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"ok": true, "id": "_design/player", "rev": "1-a"}`)
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs := []DesignDocument{
+		{
+			Document: Document{ID: "_design/player"},
+			Language: "javascript",
+			Views: map[string]DesignDocumentView{
+				"byName": {Map: "function(doc){}"},
+			},
+		},
+	}
+	match, err := MatchPattern("tenant_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := c.SeedAll(docs, match, SeedConcurrency(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results but got %+v", results)
+	}
+	a, b, cc := results[0], results[1], results[2]
+	if a.Database != "tenant_a" || a.Err != nil || a.Changed || a.Plan == nil {
+		t.Errorf("expected tenant_a to be unchanged but got %+v", a)
+	}
+	if b.Database != "tenant_b" || b.Err != nil || !b.Changed || len(b.Plan.Additions) != 1 {
+		t.Errorf("expected tenant_b to be changed but got %+v", b)
+	}
+	if cc.Database != "tenant_c" || !IsForbidden(cc.Err) || cc.Changed || cc.Plan != nil {
+		t.Errorf("expected tenant_c to fail but got %+v", cc)
+	}
+	if !reflect.DeepEqual(puts, []string{"/tenant_b/_design/player"}) {
+		t.Errorf("unexpected puts %v", puts)
+	}
+	all, err := c.SeedAll(docs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 4 || all[0].Database != "other" {
+		t.Errorf("expected all databases except _users but got %+v", all)
+	}
+	// the progress callback is not synchronized, the race detector checks SeedAll does that
+	databases := map[string]bool{}
+	_, err = c.SeedAll(docs, nil, SeedConcurrency(4), SeedStaged(func(p StagedProgress) {
+		databases[p.Database] = true
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(databases, map[string]bool{"other": true, "tenant_b": true}) {
+		t.Errorf("expected progress of other and tenant_b but got %v", databases)
+	}
+	if _, err := MatchPattern("["); err == nil {
+		t.Error("expected error for malformed pattern")
+	}
+}
+
+func TestGetWithOptions(t *testing.T) {
+	var requests []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.URL.Path, r.URL.RawQuery, r.Header.Get("Accept")))
+		if r.URL.Query().Get("open_revs") != "" {
+			fmt.Fprint(w, `[
+				{"ok": {"_id": "doc1", "_rev": "2-b", "name": "left"}},
+				{"missing": "2-x"}
+			]`)
+			return
+		}
+		fmt.Fprint(w, `{
+			"_id": "doc1", "_rev": "3-c", "name": "john",
+			"_conflicts": ["2-b"],
+			"_local_seq": 7,
+			"_revisions": {"start": 3, "ids": ["c", "a2", "a1"]},
+			"_revs_info": [{"rev": "3-c", "status": "available"}, {"rev": "2-a2", "status": "missing"}]
+		}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := c.Use("dummy")
+	type player struct {
+		Document
+		DocumentMeta
+		Name string `json:"name"`
+	}
+	var doc player
+	opts := GetOptions{
+		Rev:       pointer.String("3-c"),
+		Conflicts: pointer.Bool(true),
+		Revs:      pointer.Bool(true),
+		RevsInfo:  pointer.Bool(true),
+		LocalSeq:  pointer.Bool(true),
+	}
+	if err := db.GetWithOptions(&doc, "doc1", opts); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "john" || !reflect.DeepEqual(doc.Conflicts, []string{"2-b"}) || doc.LocalSeq != "7" {
+		t.Errorf("unexpected document %+v", doc)
+	}
+	if revs := doc.Revisions.Revs(); !reflect.DeepEqual(revs, []string{"3-c", "2-a2", "1-a1"}) {
+		t.Errorf("unexpected revisions %v", revs)
+	}
+	if len(doc.RevsInfo) != 2 || doc.RevsInfo[1].Status != "missing" {
+		t.Errorf("unexpected revs info %+v", doc.RevsInfo)
+	}
+	leaves, err := db.GetOpenRevs("doc1", []string{"2-b", "2-x"}, GetOptions{Latest: pointer.Bool(true)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(leaves) != 2 || leaves[1].Missing != "2-x" {
+		t.Fatalf("unexpected leaves %+v", leaves)
+	}
+	var left player
+	if err := leaves[0].Scan(&left); err != nil {
+		t.Fatal(err)
+	}
+	if left.Rev != "2-b" || left.Name != "left" {
+		t.Errorf("unexpected leaf %+v", left)
+	}
+	if err := leaves[1].Scan(&left); err == nil {
+		t.Error("expected error for missing revision")
+	}
+	if _, err := db.GetOpenRevs("doc1", nil, GetOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/dummy/doc1 conflicts=true&local_seq=true&rev=3-c&revs=true&revs_info=true ",
+		"/dummy/doc1 latest=true&open_revs=%5B%222-b%22%2C%222-x%22%5D application/json",
+		"/dummy/doc1 open_revs=all application/json",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf("expected requests\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(requests, "\n"))
+	}
+}
+
+func TestParseSymlinks(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"design/app/byName/map.js":   "function(doc){emit(doc.name)}",
+		"shared/user/byEmail/map.js": "function(doc){emit(doc.email)}",
+		"shared/user/lib/strings.js": "exports.upper=function(s){return s.toUpperCase()}",
+		"shared/byAge/map.js":        "function(doc){emit(doc.age)}",
+		"shared/validation/email.js": "exports.valid=function(s){return true}",
+		"shared/filters/active.js":   "function(doc,req){return doc.active}",
+	}
+	for name, data := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		"design/user":                "../shared/user",
+		"design/app/byAge":           "../../shared/byAge",
+		"design/app/filters":         "../../shared/filters",
+		"shared/user/lib/validation": "../../validation",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skipf("symbolic links are not supported: %v", err)
+		}
+	}
+	docs, err := client.Parse(filepath.Join(root, "design"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("expected 2 design documents but got %d", len(docs))
+	}
+	app, user := docs[0], docs[1]
+	if app.Views["byAge"].Map == "" || app.Filters["active"] == "" {
+		t.Errorf("expected symlinked view and filters but got %+v", app)
+	}
+	validation, ok := user.Lib["validation"].(map[string]interface{})
+	if user.ID != "_design/user" || user.Views["byEmail"].Map == "" || !ok || validation["email"] == nil {
+		t.Errorf("expected symlinked design document and module folder but got %+v", user)
+	}
+}
+
+func TestWarmUp(t *testing.T) {
+	defer func(d time.Duration) { stagedPollInterval = d }(stagedPollInterval)
+	stagedPollInterval = 5 * time.Millisecond
+	var mu sync.Mutex
+	var views int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_active_tasks" {
+			fmt.Fprint(w, `[]`)
+			return
+		}
+		mu.Lock()
+		views++
+		n := views
+		mu.Unlock()
+		switch n {
+		case 1:
+			// the client gives up while the index is built
+			time.Sleep(100 * time.Millisecond)
+		case 2:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprint(w, `{"error": "timeout", "reason": "The request could not be processed in a reasonable amount of time."}`)
+			return
+		}
+		fmt.Fprint(w, `{"total_rows": 0, "offset": 0, "rows": []}`)
+	}))
+	defer ts.Close()
+	u, err := url.Parse(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := NewClient(u, WithTimeout(30*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := &Database{Name: "dummy", Client: c}
+	report := func(StagedProgress) {}
+	if err := db.warmUp(context.Background(), "_design/player_staging", "byName", report); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	if views != 3 {
+		t.Errorf("expected 3 view queries but got %d", views)
+	}
+	mu.Unlock()
+
+	t.Run("server down", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		u, err := url.Parse(down.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		down.Close()
+		c, err := NewClient(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		db := &Database{Name: "dummy", Client: c}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = db.warmUp(ctx, "_design/player_staging", "byName", report)
+		if !IsNetworkError(err) || ctx.Err() != nil {
+			t.Errorf("expected network error before the deadline but got %v", err)
+		}
+	})
+}
@@ -0,0 +1,393 @@
+package couchdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// HighString is the string "\ufff0" which collates after nearly all other strings.
+// Use it as end key for string prefix queries like startkey="abc" and endkey="abc\ufff0".
+const HighString = "\ufff0"
+
+// HighKey is the empty object {} which collates after all other JSON values.
+// Use it as last element of composite end keys like ["player", {}].
+var HighKey = struct{}{}
+
+// PrefixRange returns the start and end key for all composite keys starting with prefix.
+//
+//	start, end := couchdb.PrefixRange("player", 2017)
+//	// start = ["player", 2017], end = ["player", 2017, {}]
+func PrefixRange(prefix ...interface{}) (start, end []interface{}) {
+	start = append([]interface{}{}, prefix...)
+	end = append(append([]interface{}{}, prefix...), HighKey)
+	return start, end
+}
+
+// StringPrefixRange returns the start and end key for all string keys starting with prefix.
+func StringPrefixRange(prefix string) (start, end string) {
+	return prefix, prefix + HighString
+}
+
+// Collate compares two view keys in CouchDB collation order and returns -1, 0 or +1.
+// http://docs.couchdb.org/en/latest/ddocs/views/collation.html
+//
+// The order is null < false < true < numbers < strings < arrays < objects.
+// Arrays and objects are compared element by element, shorter ones sort first.
+// Strings approximate the ICU root collation CouchDB uses: punctuation sorts before
+// digits and digits before letters, letters are compared ignoring accents first and
+// then ignoring case, where lowercase sorts before uppercase.
+//
+// Keys may be decoded JSON values, json.Number, json.RawMessage or any Go value
+// which marshals to JSON. Object properties are compared in the order of the
+// JSON text for json.RawMessage and in sorted order for maps and structs.
+func Collate(a, b interface{}) int {
+	return collate(normalize(a), normalize(b))
+}
+
+// number is an integer or a float, the way CouchDB decodes JSON numbers.
+type number struct {
+	i *big.Int
+	f float64
+}
+
+// member is a property of an object.
+type member struct {
+	key   string
+	value interface{}
+}
+
+// object keeps the order of properties.
+type object []member
+
+// normalize turns v into nil, bool, number, string, []interface{} or object.
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case nil, bool, string:
+		return v
+	case number, object:
+		return v
+	case json.Number:
+		return parseNumber(string(v))
+	case float64:
+		return number{f: v}
+	case float32:
+		return number{f: float64(v)}
+	case int:
+		return number{i: big.NewInt(int64(v))}
+	case int64:
+		return number{i: big.NewInt(v)}
+	case int32:
+		return number{i: big.NewInt(int64(v))}
+	case uint:
+		return number{i: new(big.Int).SetUint64(uint64(v))}
+	case uint64:
+		return number{i: new(big.Int).SetUint64(v)}
+	case []interface{}:
+		values := make([]interface{}, len(v))
+		for i, value := range v {
+			values[i] = normalize(value)
+		}
+		return values
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		o := make(object, len(keys))
+		for i, key := range keys {
+			o[i] = member{key: key, value: normalize(v[key])}
+		}
+		return o
+	case json.RawMessage:
+		return decodeOrdered(v)
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return number{i: big.NewInt(rv.Int())}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return number{i: new(big.Int).SetUint64(rv.Uint())}
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return decodeOrdered(b)
+}
+
+func parseNumber(s string) number {
+	if i, ok := new(big.Int).SetString(s, 10); ok {
+		return number{i: i}
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return number{f: f}
+}
+
+// decodeOrdered decodes JSON keeping the order of object properties.
+// Invalid JSON is treated as null.
+func decodeOrdered(data []byte) interface{} {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	v, err := decodeValue(dec)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+func decodeValue(dec *json.Decoder) (interface{}, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '[' {
+			values := []interface{}{}
+			for dec.More() {
+				v, err := decodeValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+			}
+			_, err := dec.Token()
+			return values, err
+		}
+		o := object{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			v, err := decodeValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			o = append(o, member{key: key.(string), value: v})
+		}
+		_, err := dec.Token()
+		return o, err
+	case json.Number:
+		return parseNumber(string(t)), nil
+	}
+	return token, nil
+}
+
+func rank(v interface{}) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case bool:
+		if v {
+			return 2
+		}
+		return 1
+	case number:
+		return 3
+	case string:
+		return 4
+	case []interface{}:
+		return 5
+	}
+	return 6
+}
+
+func collate(a, b interface{}) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return sign(ra - rb)
+	}
+	switch a := a.(type) {
+	case number:
+		return compareNumbers(a, b.(number))
+	case string:
+		return collateStrings(a, b.(string))
+	case []interface{}:
+		b := b.([]interface{})
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := collate(a[i], b[i]); c != 0 {
+				return c
+			}
+		}
+		return sign(len(a) - len(b))
+	case object:
+		b := b.(object)
+		for i := 0; i < len(a) && i < len(b); i++ {
+			if c := collateStrings(a[i].key, b[i].key); c != 0 {
+				return c
+			}
+			if c := collate(a[i].value, b[i].value); c != 0 {
+				return c
+			}
+		}
+		return sign(len(a) - len(b))
+	}
+	return 0
+}
+
+func compareNumbers(a, b number) int {
+	if a.i != nil && b.i != nil {
+		return a.i.Cmp(b.i)
+	}
+	x, y := a.f, b.f
+	if a.i != nil {
+		x, _ = new(big.Float).SetInt(a.i).Float64()
+	}
+	if b.i != nil {
+		y, _ = new(big.Float).SetInt(b.i).Float64()
+	}
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+// collateStrings compares two strings in an approximation of the ICU root collation.
+func collateStrings(a, b string) int {
+	if a == b {
+		return 0
+	}
+	wa, wb := weights(a), weights(b)
+	for level := 0; level < 3; level++ {
+		for i := 0; i < len(wa) && i < len(wb); i++ {
+			if wa[i][level] != wb[i][level] {
+				return sign(wa[i][level] - wb[i][level])
+			}
+		}
+		if len(wa) != len(wb) {
+			return sign(len(wa) - len(wb))
+		}
+	}
+	// strings which only differ in ignored characters
+	if a < b {
+		return -1
+	}
+	return 1
+}
+
+// asciiOrder is the order of printable ASCII characters in the ICU root collation.
+// Upper and lowercase letters share a primary weight.
+const asciiOrder = "\t\n\v\f\r _-,;:!?.'\"()[]{}@*/\\&#%`^+<=>|~$0123456789abcdefghijklmnopqrstuvwxyz"
+
+// Secondary weights of accents in the ICU root collation.
+const (
+	accentNone = iota
+	accentAcute
+	accentGrave
+	accentBreve
+	accentCircumflex
+	accentCaron
+	accentRing
+	accentDiaeresis
+	accentDoubleAcute
+	accentTilde
+	accentDot
+	accentStroke
+	accentCedilla
+	accentOgonek
+	accentMacron
+)
+
+// latin maps accented latin letters to their base letters.
+var latin = map[rune]struct {
+	base   string
+	accent int
+}{
+	'à': {"a", accentGrave}, 'á': {"a", accentAcute}, 'â': {"a", accentCircumflex},
+	'ã': {"a", accentTilde}, 'ä': {"a", accentDiaeresis}, 'å': {"a", accentRing},
+	'ā': {"a", accentMacron}, 'ă': {"a", accentBreve}, 'ą': {"a", accentOgonek},
+	'æ': {"ae", accentNone},
+	'ç': {"c", accentCedilla}, 'ć': {"c", accentAcute}, 'č': {"c", accentCaron},
+	'ď': {"d", accentCaron}, 'đ': {"d", accentStroke},
+	'è': {"e", accentGrave}, 'é': {"e", accentAcute}, 'ê': {"e", accentCircumflex},
+	'ë': {"e", accentDiaeresis}, 'ē': {"e", accentMacron}, 'ė': {"e", accentDot},
+	'ę': {"e", accentOgonek}, 'ě': {"e", accentCaron},
+	'ğ': {"g", accentBreve},
+	'ì': {"i", accentGrave}, 'í': {"i", accentAcute}, 'î': {"i", accentCircumflex},
+	'ï': {"i", accentDiaeresis}, 'ī': {"i", accentMacron},
+	'ł': {"l", accentStroke},
+	'ñ': {"n", accentTilde}, 'ń': {"n", accentAcute}, 'ň': {"n", accentCaron},
+	'ò': {"o", accentGrave}, 'ó': {"o", accentAcute}, 'ô': {"o", accentCircumflex},
+	'õ': {"o", accentTilde}, 'ö': {"o", accentDiaeresis}, 'ø': {"o", accentStroke},
+	'ō': {"o", accentMacron}, 'ő': {"o", accentDoubleAcute},
+	'œ': {"oe", accentNone},
+	'ř': {"r", accentCaron},
+	'ś': {"s", accentAcute}, 'š': {"s", accentCaron}, 'ş': {"s", accentCedilla},
+	'ß': {"ss", accentNone},
+	'ť': {"t", accentCaron},
+	'ù': {"u", accentGrave}, 'ú': {"u", accentAcute}, 'û': {"u", accentCircumflex},
+	'ü': {"u", accentDiaeresis}, 'ū': {"u", accentMacron}, 'ů': {"u", accentRing},
+	'ű': {"u", accentDoubleAcute},
+	'ý': {"y", accentAcute}, 'ÿ': {"y", accentDiaeresis},
+	'ź': {"z", accentAcute}, 'ż': {"z", accentDot}, 'ž': {"z", accentCaron},
+}
+
+// weights returns the primary, secondary and tertiary weight of every collation element in s.
+func weights(s string) [][3]int {
+	w := make([][3]int, 0, len(s))
+	for _, r := range s {
+		tertiary := 0
+		if unicode.IsUpper(r) {
+			tertiary = 1
+			r = unicode.ToLower(r)
+		}
+		if i := indexASCII(r); i >= 0 {
+			w = append(w, [3]int{i + 1, accentNone, tertiary})
+			continue
+		}
+		if l, ok := latin[r]; ok {
+			for _, base := range l.base {
+				w = append(w, [3]int{indexASCII(base) + 1, l.accent, tertiary})
+			}
+			continue
+		}
+		if r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0) {
+			// control characters are ignored
+			continue
+		}
+		primary := len(asciiOrder) + 1 + int(r)
+		if r >= 0xfff0 && r <= 0xffff {
+			// specials sort after all other characters, see HighString
+			primary += unicode.MaxRune
+		}
+		w = append(w, [3]int{primary, accentNone, tertiary})
+	}
+	return w
+}
+
+// asciiIndex is the position of every ASCII character in asciiOrder or -1.
+var asciiIndex = func() (index [0x80]int) {
+	for i := range index {
+		index[i] = -1
+	}
+	for i := 0; i < len(asciiOrder); i++ {
+		index[asciiOrder[i]] = i
+	}
+	return index
+}()
+
+func indexASCII(r rune) int {
+	if r < 0 || r >= 0x80 {
+		return -1
+	}
+	return asciiIndex[r]
+}
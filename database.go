@@ -2,6 +2,7 @@ package couchdb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"mime/multipart"
@@ -9,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/google/go-querystring/query"
@@ -17,19 +19,56 @@ import (
 // DatabaseService is an interface for dealing with a single CouchDB database.
 type DatabaseService interface {
 	AllDocs(params *QueryParameters) (*ViewResponse, error)
+	AllDocsContext(ctx context.Context, params *QueryParameters) (*ViewResponse, error)
+	AllDocsRows(params *QueryParameters) (*Rows, error)
+	AllDocsRowsContext(ctx context.Context, params *QueryParameters) (*Rows, error)
+	AllDocsQueries(queries []QueryParameters) ([]ViewResponse, error)
+	AllDocsQueriesContext(ctx context.Context, queries []QueryParameters) ([]ViewResponse, error)
 	AllDesignDocs() ([]DesignDocument, error)
+	AllDesignDocsContext(ctx context.Context) ([]DesignDocument, error)
 	Head(id string) (*http.Response, error)
+	HeadContext(ctx context.Context, id string) (*http.Response, error)
 	Get(doc CouchDoc, id string) error
+	GetContext(ctx context.Context, doc CouchDoc, id string) error
+	GetWithOptions(doc CouchDoc, id string, opts GetOptions) error
+	GetWithOptionsContext(ctx context.Context, doc CouchDoc, id string, opts GetOptions) error
+	GetOpenRevs(id string, revs []string, opts GetOptions) ([]OpenRevision, error)
+	GetOpenRevsContext(ctx context.Context, id string, revs []string, opts GetOptions) ([]OpenRevision, error)
 	Put(doc CouchDoc) (*DocumentResponse, error)
+	PutContext(ctx context.Context, doc CouchDoc) (*DocumentResponse, error)
 	Post(doc CouchDoc) (*DocumentResponse, error)
+	PostContext(ctx context.Context, doc CouchDoc) (*DocumentResponse, error)
 	Delete(doc CouchDoc) (*DocumentResponse, error)
+	DeleteContext(ctx context.Context, doc CouchDoc) (*DocumentResponse, error)
 	PutAttachment(doc CouchDoc, path string) (*DocumentResponse, error)
+	PutAttachmentContext(ctx context.Context, doc CouchDoc, path string) (*DocumentResponse, error)
 	Bulk(docs []CouchDoc) ([]DocumentResponse, error)
+	BulkContext(ctx context.Context, docs []CouchDoc) ([]DocumentResponse, error)
 	Purge(req map[string][]string) (*PurgeResponse, error)
+	PurgeContext(ctx context.Context, req map[string][]string) (*PurgeResponse, error)
 	GetSecurity() (*SecurityDocument, error)
+	GetSecurityContext(ctx context.Context) (*SecurityDocument, error)
 	PutSecurity(secDoc SecurityDocument) (*DatabaseResponse, error)
+	PutSecurityContext(ctx context.Context, secDoc SecurityDocument) (*DatabaseResponse, error)
 	View(name string) ViewService
-	Seed([]DesignDocument) error
+	Seed(cache []DesignDocument, opts ...SeedOption) error
+	SeedContext(ctx context.Context, cache []DesignDocument, opts ...SeedOption) error
+	Plan(cache []DesignDocument) (*SeedPlan, error)
+	PlanContext(ctx context.Context, cache []DesignDocument) (*SeedPlan, error)
+	Changes(params *ChangesParameters) (*ChangesResponse, error)
+	ChangesContext(ctx context.Context, params *ChangesParameters) (*ChangesResponse, error)
+	Follow(params *ChangesParameters) (*ChangesFeed, error)
+	FollowContext(ctx context.Context, params *ChangesParameters) (*ChangesFeed, error)
+	Find(query FindQuery, docs interface{}) (*FindResponse, error)
+	FindContext(ctx context.Context, query FindQuery, docs interface{}) (*FindResponse, error)
+	Explain(query FindQuery) (*ExplainResponse, error)
+	ExplainContext(ctx context.Context, query FindQuery) (*ExplainResponse, error)
+	CreateIndex(index IndexDefinition) (*CreateIndexResponse, error)
+	CreateIndexContext(ctx context.Context, index IndexDefinition) (*CreateIndexResponse, error)
+	ListIndexes() ([]IndexInfo, error)
+	ListIndexesContext(ctx context.Context) ([]IndexInfo, error)
+	DeleteIndex(ddoc, indexType, name string) (*DatabaseResponse, error)
+	DeleteIndexContext(ctx context.Context, ddoc, indexType, name string) (*DatabaseResponse, error)
 }
 
 // Database performs actions on certain database
@@ -41,39 +80,46 @@ type Database struct {
 // AllDesignDocs returns all design documents from database.
 // http://stackoverflow.com/questions/2814352/get-all-design-documents-in-couchdb
 func (db *Database) AllDesignDocs() ([]DesignDocument, error) {
-	startKey := fmt.Sprintf("%q", "_design/")
-	endKey := fmt.Sprintf("%q", "_design0")
+	return db.AllDesignDocsContext(context.Background())
+}
+
+// AllDesignDocsContext is like AllDesignDocs but takes a context.
+func (db *Database) AllDesignDocsContext(ctx context.Context) ([]DesignDocument, error) {
 	includeDocs := true
 	q := QueryParameters{
-		StartKey:    &startKey,
-		EndKey:      &endKey,
+		StartKey:    "_design/",
+		EndKey:      "_design0",
 		IncludeDocs: &includeDocs,
 	}
-	res, err := db.AllDocs(&q)
+	res, err := QueryAllDocsContext[json.RawMessage](ctx, db, &q)
 	if err != nil {
 		return nil, err
 	}
-	docs := make([]interface{}, len(res.Rows))
-	for index, row := range res.Rows {
-		docs[index] = row.Doc
-	}
-	designDocs := make([]DesignDocument, len(docs))
-	b, err := json.Marshal(docs)
-	if err != nil {
-		return nil, err
+	designDocs := make([]DesignDocument, 0, len(res.Rows))
+	for _, row := range res.Rows {
+		if row.Doc == nil {
+			continue
+		}
+		var d DesignDocument
+		if err := decodeNumbers(*row.Doc, &d); err != nil {
+			return nil, err
+		}
+		// keep the whole document for Plan
+		d.stored = *row.Doc
+		designDocs = append(designDocs, d)
 	}
-	return designDocs, json.Unmarshal(b, &designDocs)
+	return designDocs, nil
 }
 
 // AllDocs returns all documents in selected database.
 // http://docs.couchdb.org/en/latest/api/database/bulk-api.html
 func (db *Database) AllDocs(params *QueryParameters) (*ViewResponse, error) {
-	q, err := query.Values(params)
-	if err != nil {
-		return nil, err
-	}
-	u := fmt.Sprintf("%s/_all_docs?%s", url.PathEscape(db.Name), q.Encode())
-	res, err := db.Client.Request(http.MethodGet, u, nil, "")
+	return db.AllDocsContext(context.Background(), params)
+}
+
+// AllDocsContext is like AllDocs but takes a context.
+func (db *Database) AllDocsContext(ctx context.Context, params *QueryParameters) (*ViewResponse, error) {
+	res, err := db.allDocs(ctx, params)
 	if err != nil {
 		return nil, err
 	}
@@ -82,10 +128,49 @@ func (db *Database) AllDocs(params *QueryParameters) (*ViewResponse, error) {
 	return &response, json.NewDecoder(res.Body).Decode(&response)
 }
 
+// AllDocsRows is like AllDocs but returns an iterator which decodes one row at a time.
+func (db *Database) AllDocsRows(params *QueryParameters) (*Rows, error) {
+	return db.AllDocsRowsContext(context.Background(), params)
+}
+
+// AllDocsRowsContext is like AllDocsRows but takes a context.
+func (db *Database) AllDocsRowsContext(ctx context.Context, params *QueryParameters) (*Rows, error) {
+	res, err := db.allDocs(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(res.Body)
+}
+
+// AllDocsQueries executes several _all_docs queries in a single request
+// and returns one response per query in the same order.
+func (db *Database) AllDocsQueries(queries []QueryParameters) ([]ViewResponse, error) {
+	return db.AllDocsQueriesContext(context.Background(), queries)
+}
+
+// AllDocsQueriesContext is like AllDocsQueries but takes a context.
+func (db *Database) AllDocsQueriesContext(ctx context.Context, queries []QueryParameters) ([]ViewResponse, error) {
+	return db.Client.queries(ctx, fmt.Sprintf("%s/_all_docs/queries", url.PathEscape(db.Name)), queries)
+}
+
+func (db *Database) allDocs(ctx context.Context, params *QueryParameters) (*http.Response, error) {
+	q, err := params.values()
+	if err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/_all_docs?%s", url.PathEscape(db.Name), q.Encode())
+	return db.Client.RequestContext(ctx, http.MethodGet, u, nil, "")
+}
+
 // Head request.
 func (db *Database) Head(id string) (*http.Response, error) {
+	return db.HeadContext(context.Background(), id)
+}
+
+// HeadContext is like Head but takes a context.
+func (db *Database) HeadContext(ctx context.Context, id string) (*http.Response, error) {
 	u := fmt.Sprintf("%s/%s", url.PathEscape(db.Name), url.PathEscape(id))
-	body, err := db.Client.Request(http.MethodHead, u, nil, "")
+	body, err := db.Client.RequestContext(ctx, http.MethodHead, u, nil, "")
 	if err != nil {
 		return nil, err
 	}
@@ -94,8 +179,35 @@ func (db *Database) Head(id string) (*http.Response, error) {
 
 // Get document.
 func (db *Database) Get(doc CouchDoc, id string) error {
+	return db.GetContext(context.Background(), doc, id)
+}
+
+// GetContext is like Get but takes a context.
+func (db *Database) GetContext(ctx context.Context, doc CouchDoc, id string) error {
 	u := fmt.Sprintf("%s/%s", url.PathEscape(db.Name), url.PathEscape(id))
-	res, err := db.Client.Request(http.MethodGet, u, nil, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	return json.NewDecoder(res.Body).Decode(doc)
+}
+
+// GetWithOptions is like Get but takes query options, e.g. to fetch an old revision
+// or the conflicts of a document. Use DocumentMeta to decode the special fields.
+// http://docs.couchdb.org/en/latest/api/document/common.html#get--db-docid
+func (db *Database) GetWithOptions(doc CouchDoc, id string, opts GetOptions) error {
+	return db.GetWithOptionsContext(context.Background(), doc, id, opts)
+}
+
+// GetWithOptionsContext is like GetWithOptions but takes a context.
+func (db *Database) GetWithOptionsContext(ctx context.Context, doc CouchDoc, id string, opts GetOptions) error {
+	q, err := query.Values(opts)
+	if err != nil {
+		return err
+	}
+	u := fmt.Sprintf("%s/%s?%s", url.PathEscape(db.Name), url.PathEscape(id), q.Encode())
+	res, err := db.Client.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return err
 	}
@@ -103,14 +215,56 @@ func (db *Database) Get(doc CouchDoc, id string) error {
 	return json.NewDecoder(res.Body).Decode(doc)
 }
 
+// GetOpenRevs returns the leaf revisions of a document with open_revs.
+// Nil revs returns all leaves, otherwise the given revisions are fetched
+// and missing ones are reported with OpenRevision.Missing. Rev of opts is ignored.
+// http://docs.couchdb.org/en/latest/api/document/common.html#get--db-docid
+func (db *Database) GetOpenRevs(id string, revs []string, opts GetOptions) ([]OpenRevision, error) {
+	return db.GetOpenRevsContext(context.Background(), id, revs, opts)
+}
+
+// GetOpenRevsContext is like GetOpenRevs but takes a context.
+func (db *Database) GetOpenRevsContext(ctx context.Context, id string, revs []string, opts GetOptions) ([]OpenRevision, error) {
+	opts.Rev = nil
+	q, err := query.Values(opts)
+	if err != nil {
+		return nil, err
+	}
+	if revs == nil {
+		q.Set("open_revs", "all")
+	} else {
+		b, err := json.Marshal(revs)
+		if err != nil {
+			return nil, err
+		}
+		q.Set("open_revs", string(b))
+	}
+	u := fmt.Sprintf("%s/%s?%s", url.PathEscape(db.Name), url.PathEscape(id), q.Encode())
+	// without Accept CouchDB answers with multipart/mixed
+	header := http.Header{}
+	header.Set("Accept", "application/json")
+	res, err := db.Client.request(ctx, http.MethodGet, u, nil, header)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	leaves := []OpenRevision{}
+	return leaves, json.NewDecoder(res.Body).Decode(&leaves)
+}
+
 // Put document.
 func (db *Database) Put(doc CouchDoc) (*DocumentResponse, error) {
+	return db.PutContext(context.Background(), doc)
+}
+
+// PutContext is like Put but takes a context.
+func (db *Database) PutContext(ctx context.Context, doc CouchDoc) (*DocumentResponse, error) {
 	u := fmt.Sprintf("%s/%s", url.PathEscape(db.Name), url.PathEscape(doc.GetID()))
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(doc); err != nil {
 		return nil, err
 	}
-	res, err := db.Client.Request(http.MethodPut, u, &b, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodPut, u, &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -121,11 +275,16 @@ func (db *Database) Put(doc CouchDoc) (*DocumentResponse, error) {
 
 // Post document.
 func (db *Database) Post(doc CouchDoc) (*DocumentResponse, error) {
+	return db.PostContext(context.Background(), doc)
+}
+
+// PostContext is like Post but takes a context.
+func (db *Database) PostContext(ctx context.Context, doc CouchDoc) (*DocumentResponse, error) {
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(doc); err != nil {
 		return nil, err
 	}
-	res, err := db.Client.Request(http.MethodPost, url.PathEscape(db.Name), &b, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodPost, url.PathEscape(db.Name), &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -136,8 +295,13 @@ func (db *Database) Post(doc CouchDoc) (*DocumentResponse, error) {
 
 // Delete document.
 func (db *Database) Delete(doc CouchDoc) (*DocumentResponse, error) {
+	return db.DeleteContext(context.Background(), doc)
+}
+
+// DeleteContext is like Delete but takes a context.
+func (db *Database) DeleteContext(ctx context.Context, doc CouchDoc) (*DocumentResponse, error) {
 	u := fmt.Sprintf("%s/%s?rev=%s", url.PathEscape(db.Name), url.PathEscape(doc.GetID()), doc.GetRev())
-	res, err := db.Client.Request(http.MethodDelete, u, nil, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodDelete, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -148,6 +312,11 @@ func (db *Database) Delete(doc CouchDoc) (*DocumentResponse, error) {
 
 // PutAttachment adds attachment to document
 func (db *Database) PutAttachment(doc CouchDoc, path string) (*DocumentResponse, error) {
+	return db.PutAttachmentContext(context.Background(), doc, path)
+}
+
+// PutAttachmentContext is like PutAttachment but takes a context.
+func (db *Database) PutAttachmentContext(ctx context.Context, doc CouchDoc, path string) (*DocumentResponse, error) {
 
 	// target url
 	u := fmt.Sprintf("%s/%s", url.PathEscape(db.Name), url.PathEscape(doc.GetID()))
@@ -187,7 +356,7 @@ func (db *Database) PutAttachment(doc CouchDoc, path string) (*DocumentResponse,
 
 	// create http request
 	contentType := fmt.Sprintf("multipart/related; boundary=%q", writer.Boundary())
-	res, err := db.Client.Request(http.MethodPut, u, &buffer, contentType)
+	res, err := db.Client.RequestContext(ctx, http.MethodPut, u, &buffer, contentType)
 	if err != nil {
 		return nil, err
 	}
@@ -201,6 +370,11 @@ func (db *Database) PutAttachment(doc CouchDoc, path string) (*DocumentResponse,
 // creating or updating a single document, except that you batch
 // the document structure and information.
 func (db *Database) Bulk(docs []CouchDoc) ([]DocumentResponse, error) {
+	return db.BulkContext(context.Background(), docs)
+}
+
+// BulkContext is like Bulk but takes a context.
+func (db *Database) BulkContext(ctx context.Context, docs []CouchDoc) ([]DocumentResponse, error) {
 	bulk := BulkDoc{
 		Docs: docs,
 	}
@@ -209,7 +383,7 @@ func (db *Database) Bulk(docs []CouchDoc) ([]DocumentResponse, error) {
 	if err := json.NewEncoder(&b).Encode(bulk); err != nil {
 		return nil, err
 	}
-	res, err := db.Client.Request(http.MethodPost, u, &b, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodPost, u, &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -238,11 +412,16 @@ type PurgeResponse struct {
 //
 // http://docs.couchdb.org/en/1.6.1/api/database/misc.html
 func (db *Database) Purge(req map[string][]string) (*PurgeResponse, error) {
+	return db.PurgeContext(context.Background(), req)
+}
+
+// PurgeContext is like Purge but takes a context.
+func (db *Database) PurgeContext(ctx context.Context, req map[string][]string) (*PurgeResponse, error) {
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(req); err != nil {
 		return nil, err
 	}
-	res, err := db.Client.Request(http.MethodPost, url.PathEscape(db.Name)+"/_purge", &b, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodPost, url.PathEscape(db.Name)+"/_purge", &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -266,8 +445,13 @@ type SecurityDocument struct {
 // GetSecurity returns security document.
 // http://docs.couchdb.org/en/latest/api/database/security.html
 func (db *Database) GetSecurity() (*SecurityDocument, error) {
+	return db.GetSecurityContext(context.Background())
+}
+
+// GetSecurityContext is like GetSecurity but takes a context.
+func (db *Database) GetSecurityContext(ctx context.Context) (*SecurityDocument, error) {
 	u := fmt.Sprintf("%s/_security", url.PathEscape(db.Name))
-	res, err := db.Client.Request(http.MethodGet, u, nil, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -279,12 +463,17 @@ func (db *Database) GetSecurity() (*SecurityDocument, error) {
 // PutSecurity sets the security object for the given database.
 // http://docs.couchdb.org/en/latest/api/database/security.html#put--db-_security
 func (db *Database) PutSecurity(secDoc SecurityDocument) (*DatabaseResponse, error) {
+	return db.PutSecurityContext(context.Background(), secDoc)
+}
+
+// PutSecurityContext is like PutSecurity but takes a context.
+func (db *Database) PutSecurityContext(ctx context.Context, secDoc SecurityDocument) (*DatabaseResponse, error) {
 	u := fmt.Sprintf("%s/_security", url.PathEscape(db.Name))
 	var b bytes.Buffer
 	if err := json.NewEncoder(&b).Encode(secDoc); err != nil {
 		return nil, err
 	}
-	res, err := db.Client.Request(http.MethodPut, u, &b, "application/json")
+	res, err := db.Client.RequestContext(ctx, http.MethodPut, u, &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -293,88 +482,297 @@ func (db *Database) PutSecurity(secDoc SecurityDocument) (*DatabaseResponse, err
 	return r, json.NewDecoder(res.Body).Decode(r)
 }
 
+// SeedOption configures Seed.
+type SeedOption func(*seedOptions)
+
+type seedOptions struct {
+	indexes     []IndexDefinition
+	syncIndexes bool
+	staged      bool
+	progress    func(StagedProgress)
+}
+
+func newSeedOptions(opts []SeedOption) seedOptions {
+	o := seedOptions{}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SeedIndexes declares the Mango indexes of the database next to the design documents.
+// Seed creates or updates the given indexes and removes all other Mango indexes.
+func SeedIndexes(indexes ...IndexDefinition) SeedOption {
+	return func(o *seedOptions) {
+		o.indexes = append(o.indexes, indexes...)
+		o.syncIndexes = true
+	}
+}
+
 // Seed makes sure all your design documents are up to date.
-func (db *Database) Seed(cache []DesignDocument) error {
+// It applies the plan returned by Plan: design documents in the database which differ
+// from the given ones are updated, missing ones are added and all others are deleted.
+// Design documents holding Mango indexes are left alone unless SeedIndexes is given.
+func (db *Database) Seed(cache []DesignDocument, opts ...SeedOption) error {
+	return db.SeedContext(context.Background(), cache, opts...)
+}
+
+// SeedContext is like Seed but takes a context.
+func (db *Database) SeedContext(ctx context.Context, cache []DesignDocument, opts ...SeedOption) error {
+	_, err := db.seed(ctx, cache, newSeedOptions(opts))
+	return err
+}
+
+// seed applies the plan for cache and returns it.
+// The plan is nil if it could not be computed.
+func (db *Database) seed(ctx context.Context, cache []DesignDocument, o seedOptions) (*SeedPlan, error) {
+	plan, err := db.PlanContext(ctx, cache)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.apply(ctx, plan, o); err != nil {
+		return plan, err
+	}
+	if o.syncIndexes {
+		return plan, db.syncIndexes(ctx, o.indexes)
+	}
+	return plan, nil
+}
+
+// Plan returns what Seed would change without applying it.
+// Design documents are compared as a whole, including members DesignDocument does
+// not model like rewrites. Seed replaces such members with the given design documents.
+//
+//	plan, err := db.Plan(docs)
+//	fmt.Print(plan)
+func (db *Database) Plan(cache []DesignDocument) (*SeedPlan, error) {
+	return db.PlanContext(context.Background(), cache)
+}
+
+// PlanContext is like Plan but takes a context.
+func (db *Database) PlanContext(ctx context.Context, cache []DesignDocument) (*SeedPlan, error) {
 	// query all docs to get all design documents
-	designDocs, err := db.AllDesignDocs()
+	designDocs, err := db.AllDesignDocsContext(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	difference := diff(cache, designDocs)
+	plan := diff(cache, designDocs)
+	plan.Database = db.Name
+	return &plan, nil
+}
+
+// apply executes a plan.
+func (db *Database) apply(ctx context.Context, plan *SeedPlan, o seedOptions) error {
 	// remove all deletions
-	for _, doc := range difference.deletions {
-		if _, err := db.Delete(&doc); err != nil {
+	for _, doc := range plan.Deletions {
+		if _, err := db.DeleteContext(ctx, &doc); err != nil {
 			return err
 		}
 	}
-	// update all changes
-	for _, doc := range difference.changes {
-		// get design document first to get current revision
-		var old DesignDocument
-		if err := db.Get(&old, doc.ID); err != nil {
-			return err
+	// update all changes with the current revision
+	for _, change := range plan.Changes {
+		doc := change.New
+		if o.staged {
+			if err := db.deployStaged(ctx, doc, change.Old.Rev, o.progress); err != nil {
+				return err
+			}
+			continue
 		}
-		// update document with new version
-		doc.Rev = old.Rev
-		if _, err := db.Put(&doc); err != nil {
+		doc.Rev = change.Old.Rev
+		if _, err := db.PutContext(ctx, &doc); err != nil {
 			return err
 		}
 	}
 	// add all additions
-	for _, doc := range difference.additions {
-		if _, err := db.Put(&doc); err != nil {
+	for _, doc := range plan.Additions {
+		if o.staged {
+			if err := db.deployStaged(ctx, doc, "", o.progress); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, err := db.PutContext(ctx, &doc); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-type difference struct {
-	additions []DesignDocument
-	changes   []DesignDocument
-	deletions []DesignDocument
+// SeedPlan lists the design documents Seed adds, changes and deletes.
+type SeedPlan struct {
+	Database  string
+	Additions []DesignDocument
+	Changes   []DesignDocumentChange
+	Deletions []DesignDocument
+}
+
+// DesignDocumentChange is a design document which differs from the database.
+type DesignDocumentChange struct {
+	Old DesignDocument
+	New DesignDocument
+	// Fields lists what changed, e.g. "language" or "views.byAge".
+	Fields []string
+}
+
+// Empty reports whether the plan changes nothing.
+func (p *SeedPlan) Empty() bool {
+	return len(p.Additions) == 0 && len(p.Changes) == 0 && len(p.Deletions) == 0
 }
 
-func diff(cache, db []DesignDocument) difference {
-	di := difference{
-		additions: []DesignDocument{},
-		changes:   []DesignDocument{},
-		deletions: []DesignDocument{},
+// String returns a report of the plan with one line per design document.
+//
+//	players: 1 to add, 1 to change, 1 to delete
+//	  + _design/car
+//	  ~ _design/player (language, views.byName)
+//	  - _design/user
+func (p *SeedPlan) String() string {
+	var b strings.Builder
+	if p.Database != "" {
+		b.WriteString(p.Database + ": ")
+	}
+	if p.Empty() {
+		b.WriteString("no changes\n")
+		return b.String()
+	}
+	fmt.Fprintf(&b, "%d to add, %d to change, %d to delete\n", len(p.Additions), len(p.Changes), len(p.Deletions))
+	for _, doc := range p.Additions {
+		fmt.Fprintf(&b, "  + %s\n", doc.ID)
+	}
+	for _, change := range p.Changes {
+		fmt.Fprintf(&b, "  ~ %s (%s)\n", change.New.ID, strings.Join(change.Fields, ", "))
+	}
+	for _, doc := range p.Deletions {
+		fmt.Fprintf(&b, "  - %s\n", doc.ID)
+	}
+	return b.String()
+}
+
+func diff(cache, db []DesignDocument) SeedPlan {
+	plan := SeedPlan{
+		Additions: []DesignDocument{},
+		Changes:   []DesignDocumentChange{},
+		Deletions: []DesignDocument{},
+	}
+	existing := map[string]DesignDocument{}
+	for _, d := range db {
+		existing[d.ID] = d
 	}
 	// check for additions changes
 	// design document is in cache but not in db
+	declared := map[string]bool{}
 	for _, c := range cache {
-		exists := false
-		existsButDifferent := false
-		for _, d := range db {
-			if d.ID == c.ID {
-				exists = true
-				// check for different map/reduce and language
-				// do not check for different revision
-				if !reflect.DeepEqual(c.Views, d.Views) {
-					existsButDifferent = true
-				}
-			}
+		declared[c.ID] = true
+		d, ok := existing[c.ID]
+		if !ok {
+			plan.Additions = append(plan.Additions, c)
+			continue
 		}
-		if !exists {
-			di.additions = append(di.additions, c)
-		} else if existsButDifferent {
-			di.changes = append(di.changes, c)
+		if fields := changedFields(d, c); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, DesignDocumentChange{
+				Old:    d,
+				New:    c,
+				Fields: fields,
+			})
 		}
 	}
 	// check for deletions
 	// design document is in db but not in cache
 	for _, d := range db {
-		exists := false
-		for _, c := range cache {
-			if d.ID == c.ID {
-				exists = true
+		// do not delete internal design documents like _auth
+		// and Mango indexes, which are managed by SeedIndexes
+		if !declared[d.ID] && !strings.HasPrefix(d.Name(), "_") && d.Language != langQuery {
+			plan.Deletions = append(plan.Deletions, d)
+		}
+	}
+	return plan
+}
+
+// changedFields compares the JSON of two design documents and returns the changed fields.
+// Metadata like _rev is ignored and an empty language equals javascript.
+// The current design document is compared as stored if it was read by AllDesignDocs,
+// so changes to members DesignDocument does not model are found as well.
+func changedFields(current, desired DesignDocument) []string {
+	a := canonical(current)
+	if current.stored != nil {
+		a = canonicalJSON(current.stored)
+	}
+	b := canonical(desired)
+	keys := map[string]bool{}
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	fields := []string{}
+	for key := range keys {
+		if reflect.DeepEqual(a[key], b[key]) {
+			continue
+		}
+		oa, okA := a[key].(map[string]interface{})
+		ob, okB := b[key].(map[string]interface{})
+		if !okA || !okB || key == "options" {
+			fields = append(fields, key)
+			continue
+		}
+		// report single functions of views, filters, shows, lists, updates and lib
+		names := map[string]bool{}
+		for name := range oa {
+			names[name] = true
+		}
+		for name := range ob {
+			names[name] = true
+		}
+		for name := range names {
+			if !reflect.DeepEqual(oa[name], ob[name]) {
+				fields = append(fields, key+"."+name)
 			}
 		}
-		// do not delete internal design documents like _auth
-		if !exists && !strings.HasPrefix(d.Name(), "_") {
-			di.deletions = append(di.deletions, d)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// canonical returns the design document as generic JSON without metadata.
+func canonical(doc DesignDocument) map[string]interface{} {
+	b, err := json.Marshal(doc)
+	if err != nil {
+		return nil
+	}
+	return canonicalJSON(b)
+}
+
+// canonicalJSON decodes a design document into generic JSON without metadata.
+func canonicalJSON(b []byte) map[string]interface{} {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	for key := range m {
+		if strings.HasPrefix(key, "_") && key != "_id" {
+			delete(m, key)
+		}
+	}
+	pruneEmpty(m)
+	if m["language"] == nil {
+		m["language"] = langJavaScript
+	}
+	return m
+}
+
+// pruneEmpty removes empty strings and objects like omitempty does when encoding.
+func pruneEmpty(m map[string]interface{}) {
+	for key, value := range m {
+		switch value := value.(type) {
+		case string:
+			if value == "" {
+				delete(m, key)
+			}
+		case map[string]interface{}:
+			pruneEmpty(value)
+			if len(value) == 0 {
+				delete(m, key)
+			}
 		}
 	}
-	return di
 }
@@ -1,6 +1,10 @@
 package couchdb
 
-import "strings"
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
 
 const langJavaScript = "javascript"
 
@@ -8,9 +12,90 @@ const langJavaScript = "javascript"
 // http://docs.couchdb.org/en/latest/json-structure.html#design-document
 type DesignDocument struct {
 	Document
-	Language string                        `json:"language,omitempty"`
-	Views    map[string]DesignDocumentView `json:"views,omitempty"`
-	Filters  map[string]string             `json:"filters,omitempty"`
+	Language          string                        `json:"language,omitempty"`
+	Views             map[string]DesignDocumentView `json:"views,omitempty"`
+	Filters           map[string]string             `json:"filters,omitempty"`
+	Shows             map[string]string             `json:"shows,omitempty"`
+	Lists             map[string]string             `json:"lists,omitempty"`
+	Updates           map[string]string             `json:"updates,omitempty"`
+	ValidateDocUpdate string                        `json:"validate_doc_update,omitempty"`
+	// Options holds design document options like {"partitioned": false}.
+	Options map[string]interface{} `json:"options,omitempty"`
+	// Lib holds CommonJS modules which functions load with require("lib/...").
+	Lib map[string]interface{} `json:"lib,omitempty"`
+	// Extra holds the members which are not modeled, e.g. autoupdate or rewrites.
+	// They are kept when the design document is decoded and encoded again.
+	Extra map[string]interface{} `json:"-"`
+	// stored is the JSON of the design document as read by AllDesignDocs.
+	// It includes members which are not modeled, e.g. rewrites or autoupdate.
+	stored json.RawMessage
+}
+
+// designDocument has the fields of DesignDocument without its methods.
+type designDocument DesignDocument
+
+// modeledMembers are the JSON members of the DesignDocument fields.
+var modeledMembers = func() map[string]bool {
+	members := map[string]bool{}
+	t := reflect.TypeOf(designDocument{})
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			members[name] = true
+		}
+	}
+	return members
+}()
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// Members which are not modeled are kept in Extra.
+func (dd *DesignDocument) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*designDocument)(dd)); err != nil {
+		return err
+	}
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	dd.Extra = nil
+	for key, value := range members {
+		// metadata like _rev is modeled by Document or read only
+		if strings.HasPrefix(key, "_") || modeledMembers[key] {
+			continue
+		}
+		var v interface{}
+		if err := json.Unmarshal(value, &v); err != nil {
+			return err
+		}
+		if dd.Extra == nil {
+			dd.Extra = map[string]interface{}{}
+		}
+		dd.Extra[key] = v
+	}
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+// Members in Extra are added unless a field has the same name.
+func (dd DesignDocument) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(designDocument(dd))
+	if err != nil || len(dd.Extra) == 0 {
+		return b, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	members := map[string]interface{}{}
+	for key, value := range dd.Extra {
+		if !strings.HasPrefix(key, "_") && !modeledMembers[key] {
+			members[key] = value
+		}
+	}
+	for key, value := range fields {
+		members[key] = value
+	}
+	return json.Marshal(members)
 }
 
 // Name returns design document name without the "_design/" prefix
@@ -20,6 +105,44 @@ func (dd DesignDocument) Name() string {
 
 // DesignDocumentView contains map/reduce functions.
 type DesignDocumentView struct {
-	Map    string `json:"map,omitempty"`
-	Reduce string `json:"reduce,omitempty"`
+	Map     string                 `json:"map,omitempty"`
+	Reduce  string                 `json:"reduce,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"`
+	// MangoMap holds the map of a Mango index view, which is a JSON object instead of a function.
+	MangoMap json.RawMessage `json:"-"`
+}
+
+// designDocumentView has the fields of DesignDocumentView without its methods.
+type designDocumentView DesignDocumentView
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+// It accepts the object maps of Mango index views.
+func (v *DesignDocumentView) UnmarshalJSON(data []byte) error {
+	var view struct {
+		designDocumentView
+		Map json.RawMessage `json:"map"`
+	}
+	if err := json.Unmarshal(data, &view); err != nil {
+		return err
+	}
+	*v = DesignDocumentView(view.designDocumentView)
+	if len(view.Map) == 0 || string(view.Map) == "null" {
+		return nil
+	}
+	if view.Map[0] == '"' {
+		return json.Unmarshal(view.Map, &v.Map)
+	}
+	v.MangoMap = view.Map
+	return nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v DesignDocumentView) MarshalJSON() ([]byte, error) {
+	if len(v.MangoMap) == 0 {
+		return json.Marshal(designDocumentView(v))
+	}
+	return json.Marshal(struct {
+		designDocumentView
+		Map json.RawMessage `json:"map"`
+	}{designDocumentView(v), v.MangoMap})
 }
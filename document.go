@@ -1,5 +1,10 @@
 package couchdb
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // CouchDoc describes interface for every couchdb document.
 type CouchDoc interface {
 	GetID() string
@@ -38,3 +43,79 @@ func (d *Document) GetID() string {
 func (d *Document) GetRev() string {
 	return d.Rev
 }
+
+// GetOptions are the query parameters of GetWithOptions and GetOpenRevs.
+// http://docs.couchdb.org/en/latest/api/document/common.html#get--db-docid
+type GetOptions struct {
+	// Rev fetches the given revision instead of the winning one.
+	Rev              *string `url:"rev,omitempty"`
+	Attachments      *bool   `url:"attachments,omitempty"`
+	AttEncodingInfo  *bool   `url:"att_encoding_info,omitempty"`
+	Conflicts        *bool   `url:"conflicts,omitempty"`
+	DeletedConflicts *bool   `url:"deleted_conflicts,omitempty"`
+	// Latest fetches the latest leaf revisions instead of the requested ones.
+	Latest   *bool `url:"latest,omitempty"`
+	LocalSeq *bool `url:"local_seq,omitempty"`
+	// Meta is like Conflicts, DeletedConflicts and RevsInfo together.
+	Meta     *bool `url:"meta,omitempty"`
+	Revs     *bool `url:"revs,omitempty"`
+	RevsInfo *bool `url:"revs_info,omitempty"`
+}
+
+// DocumentMeta holds the special fields requested with GetOptions.
+// Embed it next to Document to decode them. CouchDB ignores them on updates
+// except for _revisions.
+//
+//	type Player struct {
+//		couchdb.Document
+//		couchdb.DocumentMeta
+//		Name string `json:"name"`
+//	}
+type DocumentMeta struct {
+	Deleted          bool           `json:"_deleted,omitempty"`
+	Conflicts        []string       `json:"_conflicts,omitempty"`
+	DeletedConflicts []string       `json:"_deleted_conflicts,omitempty"`
+	LocalSeq         Sequence       `json:"_local_seq,omitempty"`
+	Revisions        *Revisions     `json:"_revisions,omitempty"`
+	RevsInfo         []RevisionInfo `json:"_revs_info,omitempty"`
+}
+
+// Revisions is the revision history of a document.
+type Revisions struct {
+	// Start is the generation of the newest revision.
+	Start int `json:"start"`
+	// IDs are the revision hashes without generation, newest first.
+	IDs []string `json:"ids"`
+}
+
+// Revs returns the full revisions like "3-abc", newest first.
+func (r Revisions) Revs() []string {
+	revs := make([]string, len(r.IDs))
+	for i, id := range r.IDs {
+		revs[i] = fmt.Sprintf("%d-%s", r.Start-i, id)
+	}
+	return revs
+}
+
+// RevisionInfo is an entry of the revision history with its status.
+type RevisionInfo struct {
+	Rev string `json:"rev"`
+	// Status is "available", "missing" or "deleted".
+	Status string `json:"status"`
+}
+
+// OpenRevision is a leaf revision returned by GetOpenRevs.
+type OpenRevision struct {
+	// Doc is the document of the revision. It is nil if the revision is missing.
+	Doc json.RawMessage `json:"ok,omitempty"`
+	// Missing is the requested revision which does not exist.
+	Missing string `json:"missing,omitempty"`
+}
+
+// Scan decodes the document of the revision into v.
+func (r OpenRevision) Scan(v interface{}) error {
+	if r.Doc == nil {
+		return fmt.Errorf("couchdb: revision %s is missing", r.Missing)
+	}
+	return json.Unmarshal(r.Doc, v)
+}
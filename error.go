@@ -1,6 +1,34 @@
 package couchdb
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Sentinel errors matched by *Error through errors.Is.
+//
+//	if errors.Is(err, couchdb.ErrNotFound) { ... }
+var (
+	ErrBadRequest            = errors.New("couchdb: bad request")
+	ErrUnauthorized          = errors.New("couchdb: unauthorized")
+	ErrForbidden             = errors.New("couchdb: forbidden")
+	ErrNotFound              = errors.New("couchdb: not found")
+	ErrConflict              = errors.New("couchdb: conflict")
+	ErrPreconditionFailed    = errors.New("couchdb: precondition failed")
+	ErrRequestEntityTooLarge = errors.New("couchdb: request entity too large")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrBadRequest,
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusPreconditionFailed:    ErrPreconditionFailed,
+	http.StatusRequestEntityTooLarge: ErrRequestEntityTooLarge,
+}
 
 // Error describes CouchDB error.
 type Error struct {
@@ -9,10 +37,16 @@ type Error struct {
 	StatusCode int
 	Type       string `json:"error"`
 	Reason     string
+	// Body is the raw response body, truncated to 1KB.
+	Body string `json:"-"`
+	// Header contains the response headers.
+	Header http.Header `json:"-"`
+	// RequestID is the X-Couch-Request-ID header for correlating with server logs.
+	RequestID string `json:"-"`
 }
 
 func (e *Error) Error() string {
-	return fmt.Sprintf(
+	s := fmt.Sprintf(
 		"CouchDB - %s %s, Status Code: %d, Error: %s, Reason: %s",
 		e.Method,
 		e.URL,
@@ -20,4 +54,78 @@ func (e *Error) Error() string {
 		e.Type,
 		e.Reason,
 	)
+	if e.RequestID != "" {
+		s += ", Request ID: " + e.RequestID
+	}
+	return s
+}
+
+// Is reports whether the error matches one of the sentinel errors like ErrNotFound.
+func (e *Error) Is(target error) bool {
+	sentinel, ok := statusErrors[e.StatusCode]
+	return ok && sentinel == target
+}
+
+// NetworkError describes a request which did not get a response from CouchDB,
+// e.g. because the connection failed or the context was cancelled.
+type NetworkError struct {
+	Method string
+	URL    string
+	Err    error
+}
+
+func (e *NetworkError) Error() string {
+	err := e.Err
+	// the url error repeats method and url
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		err = uerr.Err
+	}
+	return fmt.Sprintf("CouchDB - %s %s, Network Error: %v", e.Method, e.URL, err)
+}
+
+// Unwrap returns the underlying cause.
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// IsNetworkError reports whether err is caused by a request that did not get a response.
+func IsNetworkError(err error) bool {
+	var nerr *NetworkError
+	return errors.As(err, &nerr)
+}
+
+// IsBadRequest reports whether err is a 400 Bad Request error.
+func IsBadRequest(err error) bool {
+	return errors.Is(err, ErrBadRequest)
+}
+
+// IsUnauthorized reports whether err is a 401 Unauthorized error.
+func IsUnauthorized(err error) bool {
+	return errors.Is(err, ErrUnauthorized)
+}
+
+// IsForbidden reports whether err is a 403 Forbidden error.
+func IsForbidden(err error) bool {
+	return errors.Is(err, ErrForbidden)
+}
+
+// IsNotFound reports whether err is a 404 Not Found error.
+func IsNotFound(err error) bool {
+	return errors.Is(err, ErrNotFound)
+}
+
+// IsConflict reports whether err is a 409 Conflict error.
+func IsConflict(err error) bool {
+	return errors.Is(err, ErrConflict)
+}
+
+// IsPreconditionFailed reports whether err is a 412 Precondition Failed error.
+func IsPreconditionFailed(err error) bool {
+	return errors.Is(err, ErrPreconditionFailed)
+}
+
+// IsRequestEntityTooLarge reports whether err is a 413 Request Entity Too Large error.
+func IsRequestEntityTooLarge(err error) bool {
+	return errors.Is(err, ErrRequestEntityTooLarge)
 }
@@ -0,0 +1,72 @@
+package couchdb
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ExplainResponse describes how CouchDB runs a Mango query.
+// http://docs.couchdb.org/en/latest/api/database/find.html#db-explain
+type ExplainResponse struct {
+	DBName      string                 `json:"dbname"`
+	Index       IndexInfo              `json:"index"`
+	Partitioned interface{}            `json:"partitioned"`
+	Selector    map[string]interface{} `json:"selector"`
+	Opts        map[string]interface{} `json:"opts"`
+	Limit       int                    `json:"limit"`
+	Skip        int                    `json:"skip"`
+	// Fields is the string "all_fields" or a list of field names.
+	Fields interface{} `json:"fields"`
+	// MRArgs contains the arguments of the underlying view query including the key range.
+	MRArgs *ExplainRange `json:"mrargs"`
+	// IndexCandidates lists the indexes CouchDB 3.x considered and why they were not chosen.
+	IndexCandidates []IndexCandidate `json:"index_candidates"`
+}
+
+// ExplainRange contains the view query arguments of an explained Mango query.
+type ExplainRange struct {
+	StartKey    interface{} `json:"start_key"`
+	EndKey      interface{} `json:"end_key"`
+	IncludeDocs bool        `json:"include_docs"`
+	ViewType    string      `json:"view_type"`
+	Reduce      bool        `json:"reduce"`
+	Partition   interface{} `json:"partition"`
+	Direction   string      `json:"direction"`
+	Stable      bool        `json:"stable"`
+	Update      interface{} `json:"update"`
+	Conflicts   interface{} `json:"conflicts"`
+}
+
+// IndexCandidate is an index which could have served an explained Mango query.
+type IndexCandidate struct {
+	Index    IndexInfo `json:"index"`
+	Analysis struct {
+		Usable  bool `json:"usable"`
+		Reasons []struct {
+			Name string `json:"name"`
+		} `json:"reasons"`
+		Ranking  int  `json:"ranking"`
+		Covering bool `json:"covering"`
+	} `json:"analysis"`
+}
+
+// FullScan reports whether the query does not use an index but scans all documents.
+func (e *ExplainResponse) FullScan() bool {
+	return e.Index.Type == IndexTypeSpecial
+}
+
+// Explain returns which index CouchDB uses for the given Mango query.
+func (db *Database) Explain(query FindQuery) (*ExplainResponse, error) {
+	return db.ExplainContext(context.Background(), query)
+}
+
+// ExplainContext is like Explain but takes a context.
+func (db *Database) ExplainContext(ctx context.Context, query FindQuery) (*ExplainResponse, error) {
+	res, err := db.mango(ctx, "_explain", query)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	response := &ExplainResponse{}
+	return response, json.NewDecoder(res.Body).Decode(response)
+}
@@ -0,0 +1,91 @@
+package couchdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// FindQuery is the request body for Mango queries.
+// http://docs.couchdb.org/en/latest/api/database/find.html#db-find
+type FindQuery struct {
+	// Selector is any value marshalling to a Mango selector, e.g. a map.
+	// A nil selector matches all documents.
+	Selector interface{} `json:"selector"`
+	Fields   []string    `json:"fields,omitempty"`
+	// Sort contains field names or objects like {"age": "desc"}.
+	Sort  []interface{} `json:"sort,omitempty"`
+	Limit *int          `json:"limit,omitempty"`
+	Skip  *int          `json:"skip,omitempty"`
+	// UseIndex is a design document name or a [design document, index name] pair.
+	UseIndex       interface{} `json:"use_index,omitempty"`
+	Conflicts      *bool       `json:"conflicts,omitempty"`
+	R              *int        `json:"r,omitempty"`
+	Bookmark       string      `json:"bookmark,omitempty"`
+	Update         *bool       `json:"update,omitempty"`
+	Stable         *bool       `json:"stable,omitempty"`
+	ExecutionStats bool        `json:"execution_stats,omitempty"`
+}
+
+// FindResponse is response from a Mango query without the documents.
+type FindResponse struct {
+	Bookmark       string          `json:"bookmark"`
+	Warning        string          `json:"warning"`
+	ExecutionStats *ExecutionStats `json:"execution_stats"`
+}
+
+// ExecutionStats is returned by Mango queries with execution_stats enabled.
+// http://docs.couchdb.org/en/latest/api/database/find.html#execution-statistics
+type ExecutionStats struct {
+	TotalKeysExamined       int     `json:"total_keys_examined"`
+	TotalDocsExamined       int     `json:"total_docs_examined"`
+	TotalQuorumDocsExamined int     `json:"total_quorum_docs_examined"`
+	ResultsReturned         int     `json:"results_returned"`
+	ExecutionTimeMs         float64 `json:"execution_time_ms"`
+}
+
+// Find runs a Mango query and decodes the matching documents into docs,
+// which must be a pointer to a slice.
+//
+//	var players []Player
+//	res, err := db.Find(couchdb.FindQuery{
+//		Selector: map[string]interface{}{"type": "player"},
+//	}, &players)
+func (db *Database) Find(query FindQuery, docs interface{}) (*FindResponse, error) {
+	return db.FindContext(context.Background(), query, docs)
+}
+
+// FindContext is like Find but takes a context.
+func (db *Database) FindContext(ctx context.Context, query FindQuery, docs interface{}) (*FindResponse, error) {
+	res, err := db.mango(ctx, "_find", query)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	response := struct {
+		FindResponse
+		Docs interface{} `json:"docs"`
+	}{
+		Docs: docs,
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+	return &response.FindResponse, nil
+}
+
+// mango posts a query to one of the Mango endpoints like _find.
+func (db *Database) mango(ctx context.Context, endpoint string, query FindQuery) (*http.Response, error) {
+	if query.Selector == nil {
+		query.Selector = map[string]interface{}{}
+	}
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(query); err != nil {
+		return nil, err
+	}
+	u := fmt.Sprintf("%s/%s", url.PathEscape(db.Name), endpoint)
+	return db.Client.RequestContext(ctx, http.MethodPost, u, &b, "application/json")
+}
@@ -0,0 +1,94 @@
+package couchdb
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// HandlerResponse is the raw response of a show, list or update function.
+// Functions set their own status codes, so StatusCode may be any code
+// like 404 or 409 and is not returned as *Error.
+type HandlerResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Show calls a show function of the design document. An empty docID calls the
+// function without document.
+// http://docs.couchdb.org/en/latest/api/ddoc/render.html#db-design-design-doc-show-show-name
+func (v *View) Show(name, docID string, params url.Values) (*HandlerResponse, error) {
+	return v.ShowContext(context.Background(), name, docID, params)
+}
+
+// ShowContext is like Show but takes a context.
+func (v *View) ShowContext(ctx context.Context, name, docID string, params url.Values) (*HandlerResponse, error) {
+	uri := fmt.Sprintf("%s_show/%s", v.URL, name)
+	if docID != "" {
+		uri += "/" + url.PathEscape(docID)
+	}
+	return v.handle(ctx, http.MethodGet, uri, params, nil, "", requestOptions{raw: true})
+}
+
+// List calls a list function of the design document with the rows of a view.
+// The view may be part of another design document when given as "<ddoc>/<view>".
+// Additional parameters of the list function are passed in params.Params.
+// http://docs.couchdb.org/en/latest/api/ddoc/render.html#db-design-design-doc-list-list-name-view-name
+func (v *View) List(name, view string, params QueryParameters) (*HandlerResponse, error) {
+	return v.ListContext(context.Background(), name, view, params)
+}
+
+// ListContext is like List but takes a context.
+func (v *View) ListContext(ctx context.Context, name, view string, params QueryParameters) (*HandlerResponse, error) {
+	q, err := params.values()
+	if err != nil {
+		return nil, err
+	}
+	uri := fmt.Sprintf("%s_list/%s/%s", v.URL, name, view)
+	return v.handle(ctx, http.MethodGet, uri, q, nil, "", requestOptions{raw: true})
+}
+
+// Update calls an update handler of the design document with the given body.
+// An empty docID lets the handler create a new document.
+// Update handlers need not be idempotent, so the request is never retried.
+// http://docs.couchdb.org/en/latest/api/ddoc/render.html#db-design-design-doc-update-update-name
+func (v *View) Update(name, docID string, params url.Values, body io.Reader, contentType string) (*HandlerResponse, error) {
+	return v.UpdateContext(context.Background(), name, docID, params, body, contentType)
+}
+
+// UpdateContext is like Update but takes a context.
+func (v *View) UpdateContext(ctx context.Context, name, docID string, params url.Values, body io.Reader, contentType string) (*HandlerResponse, error) {
+	uri := fmt.Sprintf("%s_update/%s", v.URL, name)
+	if docID != "" {
+		uri += "/" + url.PathEscape(docID)
+	}
+	return v.handle(ctx, http.MethodPost, uri, params, body, contentType, requestOptions{raw: true, once: true})
+}
+
+// handle makes a request to a show, list or update function and reads the whole response.
+func (v *View) handle(ctx context.Context, method, uri string, params url.Values, body io.Reader, contentType string, opts requestOptions) (*HandlerResponse, error) {
+	if len(params) > 0 {
+		uri += "?" + params.Encode()
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	res, err := v.Client.do(ctx, method, uri, body, header, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &HandlerResponse{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		Body:       b,
+	}, nil
+}
@@ -0,0 +1,154 @@
+package couchdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Mango index types.
+const (
+	IndexTypeJSON    = "json"
+	IndexTypeText    = "text"
+	IndexTypeSpecial = "special"
+)
+
+// langQuery is the language of design documents holding Mango indexes.
+const langQuery = "query"
+
+// Index describes the indexed fields of a Mango index.
+// http://docs.couchdb.org/en/latest/api/database/find.html#db-index
+type Index struct {
+	// Fields contains field names or objects like {"age": "asc"}.
+	// Text indexes use objects like {"name": "string"}.
+	Fields                []interface{} `json:"fields,omitempty"`
+	PartialFilterSelector interface{}   `json:"partial_filter_selector,omitempty"`
+	// The following fields are only used by text indexes.
+	DefaultField      interface{} `json:"default_field,omitempty"`
+	Analyzer          interface{} `json:"analyzer,omitempty"`
+	Selector          interface{} `json:"selector,omitempty"`
+	IndexArrayLengths *bool       `json:"index_array_lengths,omitempty"`
+}
+
+// IndexDefinition is the request body for creating a Mango index.
+// Set DDoc and Name to get stable names which are easy to manage.
+type IndexDefinition struct {
+	Index       Index  `json:"index"`
+	DDoc        string `json:"ddoc,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Partitioned *bool  `json:"partitioned,omitempty"`
+}
+
+// CreateIndexResponse is response from creating a Mango index.
+// Result is "created" or "exists".
+type CreateIndexResponse struct {
+	Result string `json:"result"`
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+}
+
+// IndexInfo describes an existing Mango index.
+type IndexInfo struct {
+	DDoc        string `json:"ddoc"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Partitioned bool   `json:"partitioned"`
+	Def         Index  `json:"def"`
+}
+
+// CreateIndex creates a Mango index. Creating an index which already exists
+// with the same definition is a no-op.
+func (db *Database) CreateIndex(index IndexDefinition) (*CreateIndexResponse, error) {
+	return db.CreateIndexContext(context.Background(), index)
+}
+
+// CreateIndexContext is like CreateIndex but takes a context.
+func (db *Database) CreateIndexContext(ctx context.Context, index IndexDefinition) (*CreateIndexResponse, error) {
+	u := fmt.Sprintf("%s/_index", url.PathEscape(db.Name))
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(index); err != nil {
+		return nil, err
+	}
+	res, err := db.Client.RequestContext(ctx, http.MethodPost, u, &b, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	response := &CreateIndexResponse{}
+	return response, json.NewDecoder(res.Body).Decode(response)
+}
+
+// ListIndexes returns all Mango indexes including the special _all_docs index.
+func (db *Database) ListIndexes() ([]IndexInfo, error) {
+	return db.ListIndexesContext(context.Background())
+}
+
+// ListIndexesContext is like ListIndexes but takes a context.
+func (db *Database) ListIndexesContext(ctx context.Context) ([]IndexInfo, error) {
+	u := fmt.Sprintf("%s/_index", url.PathEscape(db.Name))
+	res, err := db.Client.RequestContext(ctx, http.MethodGet, u, nil, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response struct {
+		Indexes []IndexInfo `json:"indexes"`
+	}
+	return response.Indexes, json.NewDecoder(res.Body).Decode(&response)
+}
+
+// DeleteIndex removes a Mango index. The design document may be given
+// with or without the "_design/" prefix, indexType is "json" or "text".
+func (db *Database) DeleteIndex(ddoc, indexType, name string) (*DatabaseResponse, error) {
+	return db.DeleteIndexContext(context.Background(), ddoc, indexType, name)
+}
+
+// DeleteIndexContext is like DeleteIndex but takes a context.
+func (db *Database) DeleteIndexContext(ctx context.Context, ddoc, indexType, name string) (*DatabaseResponse, error) {
+	u := fmt.Sprintf(
+		"%s/_index/_design/%s/%s/%s",
+		url.PathEscape(db.Name),
+		url.PathEscape(strings.TrimPrefix(ddoc, "_design/")),
+		url.PathEscape(indexType),
+		url.PathEscape(name),
+	)
+	res, err := db.Client.RequestContext(ctx, http.MethodDelete, u, nil, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	response := &DatabaseResponse{}
+	return response, json.NewDecoder(res.Body).Decode(response)
+}
+
+// syncIndexes makes sure exactly the given Mango indexes exist.
+// CouchDB only creates or updates indexes whose definition changed,
+// indexes which are not declared are removed.
+func (db *Database) syncIndexes(ctx context.Context, indexes []IndexDefinition) error {
+	existing, err := db.ListIndexesContext(ctx)
+	if err != nil {
+		return err
+	}
+	declared := map[string]bool{}
+	for _, index := range indexes {
+		res, err := db.CreateIndexContext(ctx, index)
+		if err != nil {
+			return err
+		}
+		declared[res.ID+"/"+res.Name] = true
+	}
+	for _, index := range existing {
+		if index.Type == IndexTypeSpecial || declared[index.DDoc+"/"+index.Name] {
+			continue
+		}
+		if _, err := db.DeleteIndexContext(ctx, index.DDoc, index.Type, index.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
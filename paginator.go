@@ -0,0 +1,203 @@
+package couchdb
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrNoMorePages is returned by Paginator.Next after the last page.
+var ErrNoMorePages = errors.New("couchdb: no more pages")
+
+const defaultPageSize = 25
+
+// Paginator fetches the results of a view, _all_docs or a Mango query page by page.
+//
+// Views and _all_docs are paged with startkey and startkey_docid: every request asks
+// for one row more than the page size and the additional row is where the next page starts.
+// Mango queries are paged with bookmarks.
+//
+//	p := couchdb.NewViewPaginator(db.View("player"), "byAge", couchdb.QueryParameters{}, 50)
+//	for p.HasMore() {
+//		var rows []couchdb.Row
+//		if err := p.Next(&rows); err != nil { ... }
+//	}
+//
+// The position can be handed to other processes or HTTP clients with Cursor
+// and restored with Resume.
+type Paginator struct {
+	pageSize int
+	fetch    func(ctx context.Context, dst interface{}) error
+	cursor   pageCursor
+	more     bool
+}
+
+// pageCursor is the position of a paginator.
+type pageCursor struct {
+	Key      json.RawMessage `json:"k,omitempty"`
+	DocID    string          `json:"d,omitempty"`
+	Bookmark string          `json:"b,omitempty"`
+}
+
+// NewViewPaginator returns a paginator over a view. Limit is replaced by pageSize,
+// Skip is only used for the first page. A pageSize of zero defaults to 25.
+// Next decodes the rows of a page into a pointer to a slice of Row or of a compatible struct.
+func NewViewPaginator(v ViewService, name string, params QueryParameters, pageSize int) *Paginator {
+	p := newPaginator(pageSize)
+	p.fetch = func(ctx context.Context, dst interface{}) error {
+		params := params
+		p.startAtCursor(&params)
+		res, err := v.GetContext(ctx, name, params)
+		if err != nil {
+			return err
+		}
+		return p.rowsPage(res.Rows, dst)
+	}
+	return p
+}
+
+// NewAllDocsPaginator returns a paginator over _all_docs like NewViewPaginator.
+func NewAllDocsPaginator(db DatabaseService, params QueryParameters, pageSize int) *Paginator {
+	p := newPaginator(pageSize)
+	p.fetch = func(ctx context.Context, dst interface{}) error {
+		params := params
+		p.startAtCursor(&params)
+		res, err := db.AllDocsContext(ctx, &params)
+		if err != nil {
+			return err
+		}
+		return p.rowsPage(res.Rows, dst)
+	}
+	return p
+}
+
+// NewFindPaginator returns a paginator over a Mango query. Limit is replaced by pageSize,
+// Skip is only used for the first page.
+// Next decodes the documents of a page into a pointer to a slice.
+// CouchDB does not tell whether more documents exist, so HasMore reports true until
+// a page has less than pageSize documents and the last page might be empty.
+func NewFindPaginator(db DatabaseService, query FindQuery, pageSize int) *Paginator {
+	p := newPaginator(pageSize)
+	p.fetch = func(ctx context.Context, dst interface{}) error {
+		query := query
+		query.Limit = &p.pageSize
+		if p.cursor.Bookmark != "" {
+			query.Skip = nil
+			query.Bookmark = p.cursor.Bookmark
+		}
+		var docs []json.RawMessage
+		res, err := db.FindContext(ctx, query, &docs)
+		if err != nil {
+			return err
+		}
+		p.cursor = pageCursor{Bookmark: res.Bookmark}
+		p.more = len(docs) == p.pageSize
+		return decodePage(docs, dst)
+	}
+	return p
+}
+
+func newPaginator(pageSize int) *Paginator {
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return &Paginator{
+		pageSize: pageSize,
+		more:     true,
+	}
+}
+
+// HasMore reports whether Next may return another page.
+func (p *Paginator) HasMore() bool {
+	return p.more
+}
+
+// Next fetches the next page and decodes it into dst.
+// It returns ErrNoMorePages if HasMore is false.
+func (p *Paginator) Next(dst interface{}) error {
+	return p.NextContext(context.Background(), dst)
+}
+
+// NextContext is like Next but takes a context.
+func (p *Paginator) NextContext(ctx context.Context, dst interface{}) error {
+	if !p.more {
+		return ErrNoMorePages
+	}
+	return p.fetch(ctx, dst)
+}
+
+// Cursor returns an opaque token for the position of the paginator.
+// It is empty if there are no more pages.
+func (p *Paginator) Cursor() string {
+	if !p.more {
+		return ""
+	}
+	b, err := json.Marshal(p.cursor)
+	if err != nil {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// Resume continues at the position of a token returned by Cursor.
+// An empty token starts at the first page.
+func (p *Paginator) Resume(cursor string) error {
+	var c pageCursor
+	if cursor != "" {
+		b, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return errors.New("couchdb: invalid cursor")
+		}
+		if err := json.Unmarshal(b, &c); err != nil {
+			return errors.New("couchdb: invalid cursor")
+		}
+	}
+	p.cursor = c
+	p.more = true
+	return nil
+}
+
+// startAtCursor sets the parameters for the next page of a view.
+func (p *Paginator) startAtCursor(params *QueryParameters) {
+	limit := p.pageSize + 1
+	params.Limit = &limit
+	if p.cursor.Key == nil {
+		return
+	}
+	params.Skip = nil
+	params.StartKey = p.cursor.Key
+	params.StartKeyDocID = nil
+	if p.cursor.DocID != "" {
+		docID := p.cursor.DocID
+		params.StartKeyDocID = &docID
+	}
+}
+
+// rowsPage decodes the rows of a page and remembers where the next page starts.
+func (p *Paginator) rowsPage(rows []Row, dst interface{}) error {
+	p.more = len(rows) > p.pageSize
+	if p.more {
+		next := rows[p.pageSize]
+		key, err := json.Marshal(next.Key)
+		if err != nil {
+			return err
+		}
+		p.cursor = pageCursor{Key: key, DocID: next.ID}
+		rows = rows[:p.pageSize]
+	}
+	if dst, ok := dst.(*[]Row); ok {
+		*dst = rows
+		return nil
+	}
+	return decodePage(rows, dst)
+}
+
+// decodePage decodes a page of rows or documents into dst.
+func decodePage(page interface{}, dst interface{}) error {
+	b, err := json.Marshal(page)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, dst)
+}
@@ -1,24 +1,141 @@
 package couchdb
 
+import (
+	"encoding/json"
+	"net/url"
+	"reflect"
+
+	"github.com/google/go-querystring/query"
+)
+
 // QueryParameters is struct to define url query parameters for design documents.
 // http://docs.couchdb.org/en/latest/api/ddoc/views.html#db-design-design-doc-view-view-name
+//
+// Key, Keys, StartKey and EndKey take any value which marshals to JSON,
+// e.g. strings, numbers or composite keys like []interface{}{"player", 2017}.
+// Use json.RawMessage("null") to query the null key. Nil values, including nil
+// pointers, are not sent. A *string is taken as already JSON encoded like in earlier
+// versions of this package, e.g. pointer.String(`"foo"`).
 type QueryParameters struct {
-	Conflicts       *bool   `url:"conflicts,omitempty"`
-	Descending      *bool   `url:"descending,omitempty"`
-	Group           *bool   `url:"group,omitempty"`
-	IncludeDocs     *bool   `url:"include_docs,omitempty"`
-	Attachments     *bool   `url:"attachments,omitempty"`
-	AttEncodingInfo *bool   `url:"att_encoding_info,omitempty"`
-	InclusiveEnd    *bool   `url:"inclusive_end,omitempty"`
-	Reduce          *bool   `url:"reduce,omitempty"`
-	UpdateSeq       *bool   `url:"update_seq,omitempty"`
-	GroupLevel      *int    `url:"group_level,omitempty"`
-	Limit           *int    `url:"limit,omitempty"`
-	Skip            *int    `url:"skip,omitempty"`
-	Key             *string `url:"key,omitempty"`
-	EndKey          *string `url:"endkey,comma,omitempty"`
-	EndKeyDocID     *string `url:"end_key_doc_id,omitempty"`
-	Stale           *string `url:"stale,omitempty"`
-	StartKey        *string `url:"startkey,comma,omitempty"`
-	StartKeyDocID   *string `url:"startkey_docid,omitempty"`
+	Conflicts       *bool         `url:"conflicts,omitempty" json:"conflicts,omitempty"`
+	Descending      *bool         `url:"descending,omitempty" json:"descending,omitempty"`
+	Group           *bool         `url:"group,omitempty" json:"group,omitempty"`
+	IncludeDocs     *bool         `url:"include_docs,omitempty" json:"include_docs,omitempty"`
+	Attachments     *bool         `url:"attachments,omitempty" json:"attachments,omitempty"`
+	AttEncodingInfo *bool         `url:"att_encoding_info,omitempty" json:"att_encoding_info,omitempty"`
+	InclusiveEnd    *bool         `url:"inclusive_end,omitempty" json:"inclusive_end,omitempty"`
+	Reduce          *bool         `url:"reduce,omitempty" json:"reduce,omitempty"`
+	Sorted          *bool         `url:"sorted,omitempty" json:"sorted,omitempty"`
+	Stable          *bool         `url:"stable,omitempty" json:"stable,omitempty"`
+	UpdateSeq       *bool         `url:"update_seq,omitempty" json:"update_seq,omitempty"`
+	GroupLevel      *int          `url:"group_level,omitempty" json:"group_level,omitempty"`
+	Limit           *int          `url:"limit,omitempty" json:"limit,omitempty"`
+	Skip            *int          `url:"skip,omitempty" json:"skip,omitempty"`
+	Key             interface{}   `url:"-" json:"key,omitempty"`
+	Keys            []interface{} `url:"-" json:"keys,omitempty"`
+	EndKey          interface{}   `url:"-" json:"endkey,omitempty"`
+	EndKeyDocID     *string       `url:"endkey_docid,omitempty" json:"endkey_docid,omitempty"`
+	// Stale is deprecated since CouchDB 2.1, use Stable and Update instead.
+	Stale         *string     `url:"stale,omitempty" json:"stale,omitempty"`
+	StartKey      interface{} `url:"-" json:"startkey,omitempty"`
+	StartKeyDocID *string     `url:"startkey_docid,omitempty" json:"startkey_docid,omitempty"`
+	// Update is "true", "false" or "lazy".
+	Update *string `url:"update,omitempty" json:"update,omitempty"`
+	// Params holds additional query parameters, e.g. for list functions.
+	Params url.Values `url:"-" json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler. Keys are encoded like in query strings.
+func (p QueryParameters) MarshalJSON() ([]byte, error) {
+	type queryParameters QueryParameters
+	aux := queryParameters(p)
+	for _, key := range []*interface{}{&aux.Key, &aux.StartKey, &aux.EndKey} {
+		b, err := encodeKey(*key)
+		if err != nil {
+			return nil, err
+		}
+		*key = nil
+		if b != nil {
+			*key = b
+		}
+	}
+	return json.Marshal(aux)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It also accepts the aliases
+// start_key, end_key, start_key_doc_id and end_key_doc_id.
+func (p *QueryParameters) UnmarshalJSON(data []byte) error {
+	type queryParameters QueryParameters
+	aux := struct {
+		*queryParameters
+		StartKeyAlias      interface{} `json:"start_key"`
+		EndKeyAlias        interface{} `json:"end_key"`
+		StartKeyDocIDAlias *string     `json:"start_key_doc_id"`
+		EndKeyDocIDAlias   *string     `json:"end_key_doc_id"`
+	}{
+		queryParameters: (*queryParameters)(p),
+	}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	if p.StartKey == nil {
+		p.StartKey = aux.StartKeyAlias
+	}
+	if p.EndKey == nil {
+		p.EndKey = aux.EndKeyAlias
+	}
+	if p.StartKeyDocID == nil {
+		p.StartKeyDocID = aux.StartKeyDocIDAlias
+	}
+	if p.EndKeyDocID == nil {
+		p.EndKeyDocID = aux.EndKeyDocIDAlias
+	}
+	return nil
+}
+
+// values returns the url query with JSON encoded keys.
+func (p *QueryParameters) values() (url.Values, error) {
+	if p == nil {
+		return url.Values{}, nil
+	}
+	q, err := query.Values(p)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range p.Params {
+		q[key] = values
+	}
+	keys := map[string]interface{}{
+		"key":      p.Key,
+		"startkey": p.StartKey,
+		"endkey":   p.EndKey,
+	}
+	if p.Keys != nil {
+		keys["keys"] = p.Keys
+	}
+	for name, value := range keys {
+		b, err := encodeKey(value)
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			q.Set(name, string(b))
+		}
+	}
+	return q, nil
+}
+
+// encodeKey returns the JSON encoding of a key or nil if the key is unset.
+// A *string is already JSON encoded.
+func encodeKey(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return nil, nil
+	}
+	if s, ok := v.(*string); ok {
+		return json.RawMessage(*s), nil
+	}
+	return json.Marshal(v)
 }
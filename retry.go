@@ -0,0 +1,207 @@
+package couchdb
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy describes how requests failing with transient errors are retried.
+// Transient errors are network errors, 429 Too Many Requests, 502, 503 and 504
+// responses as well as 500 responses with a CouchDB "timeout" error.
+//
+// Only idempotent methods are retried and only if the request body can be replayed.
+// Request bodies passed as *bytes.Buffer, *bytes.Reader or *strings.Reader are replayable.
+// Note that a retried PUT or DELETE whose first attempt reached CouchDB
+// reports a 409 conflict because the revision has already changed.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first one.
+	// A value of 1 or less disables retries.
+	MaxAttempts int
+	// MinBackoff is the delay before the first retry. It doubles with every further attempt.
+	// Defaults to 100ms.
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between two attempts, including delays requested
+	// by a Retry-After header. Defaults to 10s.
+	MaxBackoff time.Duration
+	// Jitter is the fraction between 0 and 1 of every delay that is randomized.
+	Jitter float64
+	// Methods lists the HTTP methods which are retried.
+	// Defaults to GET, HEAD, PUT, DELETE and OPTIONS.
+	Methods []string
+	// OnAttempt is called after every failed attempt. It may be nil.
+	OnAttempt func(RetryAttempt)
+}
+
+// DefaultRetryPolicy is a sensible policy for clustered CouchDB setups.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Jitter:      0.5,
+}
+
+// RetryAttempt describes a single failed attempt passed to RetryPolicy.OnAttempt.
+type RetryAttempt struct {
+	// Attempt is the number of the attempt starting at 1.
+	Attempt    int
+	Method     string
+	URL        string
+	StatusCode int
+	Err        error
+	// Retry is true if another attempt follows after Delay.
+	Retry bool
+	Delay time.Duration
+}
+
+const (
+	defaultMinBackoff = 100 * time.Millisecond
+	defaultMaxBackoff = 10 * time.Second
+)
+
+var idempotentMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPut,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// WithRetryPolicy sets the retry policy for all requests made by the client.
+func WithRetryPolicy(p RetryPolicy) ClientOption {
+	return func(c *Client) error {
+		c.Retry = &p
+		return nil
+	}
+}
+
+type retryPolicyKey struct{}
+
+// ContextWithRetryPolicy returns a context which overrides the client retry policy
+// for all requests made with it. Use RetryPolicy{} to disable retries for a single call.
+func ContextWithRetryPolicy(ctx context.Context, p RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{}, &p)
+}
+
+// retryPolicy returns the policy for a request with the given context.
+// It returns nil if requests should not be retried.
+func (c *Client) retryPolicy(ctx context.Context) *RetryPolicy {
+	if p, ok := ctx.Value(retryPolicyKey{}).(*RetryPolicy); ok {
+		return p
+	}
+	return c.Retry
+}
+
+// attempts returns the maximum number of attempts for the given method.
+func (p *RetryPolicy) attempts(method string) int {
+	if p == nil || p.MaxAttempts <= 1 {
+		return 1
+	}
+	methods := p.Methods
+	if methods == nil {
+		methods = idempotentMethods
+	}
+	for _, m := range methods {
+		if m == method {
+			return p.MaxAttempts
+		}
+	}
+	return 1
+}
+
+// retryable reports whether the error returned by an attempt is transient.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var cerr *Error
+	if errors.As(err, &cerr) {
+		switch cerr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		case http.StatusInternalServerError:
+			return cerr.Type == "timeout"
+		}
+		return false
+	}
+	// certificate problems do not go away by trying again
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostname x509.HostnameError
+	var invalid x509.CertificateInvalidError
+	if errors.As(err, &unknownAuthority) || errors.As(err, &hostname) || errors.As(err, &invalid) {
+		return false
+	}
+	return true
+}
+
+// backoff returns the delay before the next attempt.
+// A valid Retry-After header takes precedence over the exponential backoff.
+func (p *RetryPolicy) backoff(attempt int, retryAfter string) time.Duration {
+	max := p.MaxBackoff
+	if max <= 0 {
+		max = defaultMaxBackoff
+	}
+	if d, ok := parseRetryAfter(retryAfter); ok {
+		if d > max {
+			return max
+		}
+		return d
+	}
+	d := p.MinBackoff
+	if d <= 0 {
+		d = defaultMinBackoff
+	}
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d
+}
+
+// parseRetryAfter parses the value of a Retry-After header,
+// which is either a number of seconds or an HTTP date.
+func parseRetryAfter(s string) (time.Duration, bool) {
+	if s == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(s)
+	if err != nil {
+		return 0, false
+	}
+	d := time.Until(t)
+	if d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// sleep waits for the given duration or until the context is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
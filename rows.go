@@ -0,0 +1,212 @@
+package couchdb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Rows is an iterator over the rows of a view or _all_docs response.
+// Rows are decoded one at a time while reading the response body,
+// so large results do not have to fit into memory.
+//
+//	rows, err := db.View("player").Rows("byAge", params)
+//	...
+//	defer rows.Close()
+//	for rows.Next() {
+//		var age int
+//		var player Player
+//		if err := rows.ScanKey(&age); err != nil { ... }
+//		if err := rows.ScanDoc(&player); err != nil { ... }
+//	}
+//	if err := rows.Err(); err != nil { ... }
+type Rows struct {
+	body      io.ReadCloser
+	decoder   *json.Decoder
+	row       rawRow
+	totalRows int
+	offset    int
+	updateSeq Sequence
+	err       error
+	done      bool
+}
+
+// rawRow is a row with undecoded key, value and doc.
+type rawRow struct {
+	ID    string          `json:"id"`
+	Key   json.RawMessage `json:"key"`
+	Value json.RawMessage `json:"value"`
+	Doc   json.RawMessage `json:"doc"`
+	// Error is set for keys of _all_docs which do not exist, e.g. "not_found".
+	Error string `json:"error"`
+}
+
+// newRows reads the response up to the first row.
+func newRows(body io.ReadCloser) (*Rows, error) {
+	r := &Rows{
+		body:    body,
+		decoder: json.NewDecoder(body),
+	}
+	if err := r.open(); err != nil {
+		body.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Next advances to the next row. It returns false after the last row or if an error occurred.
+// The response body is closed once all rows have been read.
+func (r *Rows) Next() bool {
+	if r.done {
+		return false
+	}
+	if r.decoder.More() {
+		r.row = rawRow{}
+		if err := r.decoder.Decode(&r.row); err != nil {
+			r.err = err
+			r.Close()
+			return false
+		}
+		return true
+	}
+	r.err = r.readTrailer()
+	r.Close()
+	return false
+}
+
+// ID returns the document ID of the current row. It is empty for reduced rows.
+func (r *Rows) ID() string {
+	return r.row.ID
+}
+
+// RowError returns the error of the current row, e.g. "not_found"
+// for keys of _all_docs which do not exist.
+func (r *Rows) RowError() string {
+	return r.row.Error
+}
+
+// ScanKey decodes the key of the current row into v.
+func (r *Rows) ScanKey(v interface{}) error {
+	return scanRow(r.row.Key, "key", v)
+}
+
+// ScanValue decodes the value of the current row into v.
+func (r *Rows) ScanValue(v interface{}) error {
+	return scanRow(r.row.Value, "value", v)
+}
+
+// ScanDoc decodes the document of the current row into v.
+// Documents are only included with IncludeDocs.
+func (r *Rows) ScanDoc(v interface{}) error {
+	return scanRow(r.row.Doc, "doc", v)
+}
+
+// Row returns the current row with generic key, value and doc.
+func (r *Rows) Row() (Row, error) {
+	row := Row{ID: r.row.ID}
+	if r.row.Key != nil {
+		if err := json.Unmarshal(r.row.Key, &row.Key); err != nil {
+			return row, err
+		}
+	}
+	if r.row.Value != nil {
+		if err := json.Unmarshal(r.row.Value, &row.Value); err != nil {
+			return row, err
+		}
+	}
+	if r.row.Doc != nil {
+		if err := json.Unmarshal(r.row.Doc, &row.Doc); err != nil {
+			return row, err
+		}
+	}
+	return row, nil
+}
+
+// TotalRows returns total_rows of the response. Like Offset and UpdateSeq it is
+// available as soon as it has been read, which is before the first row for CouchDB.
+func (r *Rows) TotalRows() int {
+	return r.totalRows
+}
+
+// Offset returns the offset of the first row.
+func (r *Rows) Offset() int {
+	return r.offset
+}
+
+// UpdateSeq returns the update sequence of the view if requested with UpdateSeq.
+func (r *Rows) UpdateSeq() Sequence {
+	return r.updateSeq
+}
+
+// Err returns the error which stopped the iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close closes the response body. It is safe to call Close before all rows have been read.
+func (r *Rows) Close() error {
+	if r.done {
+		return nil
+	}
+	r.done = true
+	return r.body.Close()
+}
+
+// open reads the response object up to the start of the rows array.
+func (r *Rows) open() error {
+	if err := expectDelim(r.decoder, '{'); err != nil {
+		return err
+	}
+	for r.decoder.More() {
+		key, err := r.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if key == "rows" {
+			return expectDelim(r.decoder, '[')
+		}
+		if err := r.decodeField(key); err != nil {
+			return err
+		}
+	}
+	return errors.New("couchdb: view response has no rows")
+}
+
+// readTrailer reads the fields after the rows array.
+func (r *Rows) readTrailer() error {
+	if err := expectDelim(r.decoder, ']'); err != nil {
+		return err
+	}
+	for r.decoder.More() {
+		key, err := r.decoder.Token()
+		if err != nil {
+			return err
+		}
+		if err := r.decodeField(key); err != nil {
+			return err
+		}
+	}
+	return expectDelim(r.decoder, '}')
+}
+
+// decodeField decodes the value of a top level field other than rows.
+func (r *Rows) decodeField(key json.Token) error {
+	switch key {
+	case "total_rows":
+		return r.decoder.Decode(&r.totalRows)
+	case "offset":
+		return r.decoder.Decode(&r.offset)
+	case "update_seq":
+		return r.decoder.Decode(&r.updateSeq)
+	}
+	var skip json.RawMessage
+	return r.decoder.Decode(&skip)
+}
+
+func scanRow(data json.RawMessage, field string, v interface{}) error {
+	if data == nil {
+		return fmt.Errorf("couchdb: row has no %s", field)
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,133 @@
+package couchdb
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+)
+
+const defaultSeedConcurrency = 4
+
+// SeedResult is the outcome of seeding one database with SeedAll.
+type SeedResult struct {
+	Database string
+	// Plan is what was applied. It is nil if the plan could not be computed.
+	Plan *SeedPlan
+	// Changed reports whether design documents were added, changed or deleted.
+	// It may be true together with Err if applying the plan failed halfway.
+	Changed bool
+	Err     error
+}
+
+// SeedAllOption configures SeedAll. Every SeedOption is a SeedAllOption
+// and applies to all selected databases.
+type SeedAllOption interface {
+	applySeedAll(*seedAllOptions)
+}
+
+type seedAllOptions struct {
+	concurrency int
+	seed        []SeedOption
+}
+
+func (opt SeedOption) applySeedAll(o *seedAllOptions) {
+	o.seed = append(o.seed, opt)
+}
+
+type seedConcurrency int
+
+func (n seedConcurrency) applySeedAll(o *seedAllOptions) {
+	if n > 0 {
+		o.concurrency = int(n)
+	}
+}
+
+// SeedConcurrency sets how many databases SeedAll seeds at the same time.
+// The default is 4.
+func SeedConcurrency(n int) SeedAllOption {
+	return seedConcurrency(n)
+}
+
+// MatchPattern returns a function for SeedAll which selects databases whose name
+// matches the shell pattern, e.g. "tenant_*". See path.Match for the syntax.
+func MatchPattern(pattern string) (func(name string) bool, error) {
+	// check the syntax once instead of failing every match
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	return func(name string) bool {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}, nil
+}
+
+// SeedAll seeds every database selected by match with the same design documents
+// like Seed. A nil match selects all databases except system databases starting
+// with an underscore. Databases are seeded concurrently, see SeedConcurrency.
+// A failing database does not stop the others; its error is in its result.
+// The progress callback of SeedStaged is never called concurrently.
+// The results are in the order of All. The error is only set if listing the databases failed.
+//
+//	match, err := couchdb.MatchPattern("tenant_*")
+//	results, err := client.SeedAll(docs, match, couchdb.SeedConcurrency(8))
+//	for _, r := range results {
+//		if r.Err != nil { ... }
+//	}
+func (c *Client) SeedAll(cache []DesignDocument, match func(name string) bool, opts ...SeedAllOption) ([]SeedResult, error) {
+	return c.SeedAllContext(context.Background(), cache, match, opts...)
+}
+
+// SeedAllContext is like SeedAll but takes a context.
+func (c *Client) SeedAllContext(ctx context.Context, cache []DesignDocument, match func(name string) bool, opts ...SeedAllOption) ([]SeedResult, error) {
+	all := seedAllOptions{
+		concurrency: defaultSeedConcurrency,
+	}
+	for _, opt := range opts {
+		opt.applySeedAll(&all)
+	}
+	o := newSeedOptions(all.seed)
+	if progress := o.progress; progress != nil {
+		// databases are seeded concurrently but the callback need not be safe for that
+		var mu sync.Mutex
+		o.progress = func(p StagedProgress) {
+			mu.Lock()
+			defer mu.Unlock()
+			progress(p)
+		}
+	}
+	names, err := c.AllContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	results := []SeedResult{}
+	for _, name := range names {
+		if match == nil && strings.HasPrefix(name, "_") || match != nil && !match(name) {
+			continue
+		}
+		results = append(results, SeedResult{Database: name})
+	}
+	// seed with a fixed number of workers
+	jobs := make(chan *SeedResult)
+	var wg sync.WaitGroup
+	for i := 0; i < all.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range jobs {
+				db := &Database{
+					Name:   r.Database,
+					Client: c,
+				}
+				r.Plan, r.Err = db.seed(ctx, cache, o)
+				r.Changed = r.Plan != nil && !r.Plan.Empty()
+			}
+		}()
+	}
+	for i := range results {
+		jobs <- &results[i]
+	}
+	close(jobs)
+	wg.Wait()
+	return results, nil
+}
@@ -0,0 +1,113 @@
+package selector
+
+// FieldRef refers to a document field and builds conditions on it.
+type FieldRef struct {
+	name string
+}
+
+// Field refers to the field with the given name. Nested fields use dots
+// like "address.city", literal dots in field names are escaped as "\\.".
+func Field(name string) FieldRef {
+	return FieldRef{name: name}
+}
+
+// Value refers to the value itself instead of a field. It is used inside
+// ElemMatch and AllMatch for arrays of scalars.
+//
+//	selector.Field("tags").ElemMatch(selector.Value().Eq("go"))
+func Value() FieldRef {
+	return FieldRef{}
+}
+
+func (f FieldRef) condition(op string, arg interface{}) Selector {
+	return Selector{op: op, field: f.name, arg: arg}
+}
+
+// Eq matches fields equal to v.
+func (f FieldRef) Eq(v interface{}) Selector {
+	return f.condition(OpEq, v)
+}
+
+// Ne matches fields not equal to v.
+func (f FieldRef) Ne(v interface{}) Selector {
+	return f.condition(OpNe, v)
+}
+
+// Gt matches fields greater than v.
+func (f FieldRef) Gt(v interface{}) Selector {
+	return f.condition(OpGt, v)
+}
+
+// Gte matches fields greater than or equal to v.
+func (f FieldRef) Gte(v interface{}) Selector {
+	return f.condition(OpGte, v)
+}
+
+// Lt matches fields less than v.
+func (f FieldRef) Lt(v interface{}) Selector {
+	return f.condition(OpLt, v)
+}
+
+// Lte matches fields less than or equal to v.
+func (f FieldRef) Lte(v interface{}) Selector {
+	return f.condition(OpLte, v)
+}
+
+// In matches fields equal to any of the values.
+func (f FieldRef) In(values ...interface{}) Selector {
+	return f.condition(OpIn, list(values))
+}
+
+// Nin matches fields equal to none of the values.
+func (f FieldRef) Nin(values ...interface{}) Selector {
+	return f.condition(OpNin, list(values))
+}
+
+// Exists matches documents which have the field if exists is true
+// and documents without the field otherwise.
+func (f FieldRef) Exists(exists bool) Selector {
+	return f.condition(OpExists, exists)
+}
+
+// Type matches fields of the given JSON type like TypeString.
+func (f FieldRef) Type(t string) Selector {
+	return f.condition(OpType, t)
+}
+
+// Size matches arrays with the given length.
+func (f FieldRef) Size(n int) Selector {
+	return f.condition(OpSize, n)
+}
+
+// Mod matches integer fields where field % divisor == remainder.
+func (f FieldRef) Mod(divisor, remainder int) Selector {
+	return f.condition(OpMod, []int{divisor, remainder})
+}
+
+// Regex matches string fields against a PCRE regular expression.
+func (f FieldRef) Regex(pattern string) Selector {
+	return f.condition(OpRegex, pattern)
+}
+
+// All matches arrays containing all of the values.
+func (f FieldRef) All(values ...interface{}) Selector {
+	return f.condition(OpAll, list(values))
+}
+
+// ElemMatch matches arrays with at least one element matching s.
+func (f FieldRef) ElemMatch(s Selector) Selector {
+	return f.condition(OpElemMatch, s)
+}
+
+// AllMatch matches arrays whose elements all match s.
+func (f FieldRef) AllMatch(s Selector) Selector {
+	return f.condition(OpAllMatch, s)
+}
+
+// list makes sure an empty argument list marshals to [] instead of null.
+func list(values []interface{}) []interface{} {
+	if values == nil {
+		return []interface{}{}
+	}
+	return values
+}
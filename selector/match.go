@@ -0,0 +1,355 @@
+package selector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/go-base-lib/couchdb"
+)
+
+// Match reports whether doc matches the selector. doc is any value which marshals
+// to a JSON object, e.g. a struct or a map. Matching follows CouchDB semantics:
+// a missing field fails every condition except {"$exists": false}, so
+// Not(Field("a").Eq(1)) matches documents without the field a.
+//
+// Regular expressions use the Go syntax, which is a subset of the PCRE syntax
+// used by CouchDB.
+func (s Selector) Match(doc interface{}) (bool, error) {
+	m, err := compile(s)
+	if err != nil {
+		return false, err
+	}
+	v, err := toJSON(doc)
+	if err != nil {
+		return false, err
+	}
+	return m(v), nil
+}
+
+// matcher matches a decoded JSON value.
+type matcher func(v interface{}) bool
+
+func compile(s Selector) (matcher, error) {
+	switch s.op {
+	case "":
+		return func(interface{}) bool { return true }, nil
+	case OpAnd, OpOr, OpNor:
+		matchers := make([]matcher, len(s.selectors))
+		for i, operand := range s.selectors {
+			m, err := compile(operand)
+			if err != nil {
+				return nil, err
+			}
+			matchers[i] = m
+		}
+		matchAny := func(v interface{}) bool {
+			for _, m := range matchers {
+				if m(v) {
+					return true
+				}
+			}
+			return false
+		}
+		switch s.op {
+		case OpOr:
+			return matchAny, nil
+		case OpNor:
+			return func(v interface{}) bool { return !matchAny(v) }, nil
+		}
+		return func(v interface{}) bool {
+			for _, m := range matchers {
+				if !m(v) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	case OpNot:
+		m, err := compile(s.selectors[0])
+		if err != nil {
+			return nil, err
+		}
+		return func(v interface{}) bool { return !m(v) }, nil
+	}
+	cmp := couchdb.Collate
+	if s.field == "_id" {
+		cmp = compareRaw
+	}
+	cond, err := condition(s.op, s.arg, cmp)
+	if err != nil {
+		return nil, err
+	}
+	if s.field == "" {
+		return cond, nil
+	}
+	path := splitPath(s.field)
+	// {"$exists": false} is the only condition matching missing fields
+	matchMissing := s.op == OpExists && s.arg == false
+	return func(v interface{}) bool {
+		value, found, ok := field(v, path)
+		if !ok {
+			return false
+		}
+		if !found {
+			return matchMissing
+		}
+		return cond(value)
+	}, nil
+}
+
+// condition compiles a condition on a field value.
+func condition(op string, arg interface{}, cmp func(a, b interface{}) int) (matcher, error) {
+	switch op {
+	case OpElemMatch, OpAllMatch:
+		s, ok := arg.(Selector)
+		if !ok {
+			return nil, fmt.Errorf("selector: %s requires a selector", op)
+		}
+		m, err := compile(s)
+		if err != nil {
+			return nil, err
+		}
+		if op == OpElemMatch {
+			return func(v interface{}) bool {
+				values, ok := v.([]interface{})
+				if !ok {
+					return false
+				}
+				for _, value := range values {
+					if m(value) {
+						return true
+					}
+				}
+				return false
+			}, nil
+		}
+		return func(v interface{}) bool {
+			values, ok := v.([]interface{})
+			if !ok || len(values) == 0 {
+				return false
+			}
+			for _, value := range values {
+				if !m(value) {
+					return false
+				}
+			}
+			return true
+		}, nil
+	}
+	arg, err := toJSON(arg)
+	if err != nil {
+		return nil, err
+	}
+	invalid := func() error {
+		return fmt.Errorf("selector: invalid argument for %s: %v", op, arg)
+	}
+	switch op {
+	case OpEq:
+		return func(v interface{}) bool { return cmp(v, arg) == 0 }, nil
+	case OpNe:
+		return func(v interface{}) bool { return cmp(v, arg) != 0 }, nil
+	case OpGt:
+		return func(v interface{}) bool { return cmp(v, arg) > 0 }, nil
+	case OpGte:
+		return func(v interface{}) bool { return cmp(v, arg) >= 0 }, nil
+	case OpLt:
+		return func(v interface{}) bool { return cmp(v, arg) < 0 }, nil
+	case OpLte:
+		return func(v interface{}) bool { return cmp(v, arg) <= 0 }, nil
+	case OpIn, OpNin:
+		args, ok := arg.([]interface{})
+		if !ok {
+			return nil, invalid()
+		}
+		in := func(v interface{}) bool {
+			values, ok := v.([]interface{})
+			if !ok {
+				values = []interface{}{v}
+			}
+			for _, a := range args {
+				for _, value := range values {
+					if cmp(value, a) == 0 {
+						return true
+					}
+				}
+			}
+			return false
+		}
+		if op == OpNin {
+			return func(v interface{}) bool { return !in(v) }, nil
+		}
+		return in, nil
+	case OpAll:
+		args, ok := arg.([]interface{})
+		if !ok {
+			return nil, invalid()
+		}
+		return func(v interface{}) bool {
+			values, ok := v.([]interface{})
+			if !ok || len(args) == 0 {
+				return false
+			}
+			// a single array argument also matches an equal array
+			if len(args) == 1 {
+				if a, ok := args[0].([]interface{}); ok && cmp(a, values) == 0 {
+					return true
+				}
+			}
+		next:
+			for _, a := range args {
+				for _, value := range values {
+					if cmp(value, a) == 0 {
+						continue next
+					}
+				}
+				return false
+			}
+			return true
+		}, nil
+	case OpExists:
+		exists, ok := arg.(bool)
+		if !ok {
+			return nil, invalid()
+		}
+		return func(interface{}) bool { return exists }, nil
+	case OpType:
+		t, ok := arg.(string)
+		if !ok {
+			return nil, invalid()
+		}
+		return func(v interface{}) bool { return typeOf(v) == t }, nil
+	case OpSize:
+		size, ok := integer(arg)
+		if !ok || size < 0 {
+			return nil, invalid()
+		}
+		return func(v interface{}) bool {
+			values, ok := v.([]interface{})
+			return ok && int64(len(values)) == size
+		}, nil
+	case OpMod:
+		args, ok := arg.([]interface{})
+		if !ok || len(args) != 2 {
+			return nil, invalid()
+		}
+		divisor, ok := integer(args[0])
+		if !ok || divisor == 0 {
+			return nil, invalid()
+		}
+		remainder, ok := integer(args[1])
+		if !ok {
+			return nil, invalid()
+		}
+		return func(v interface{}) bool {
+			n, ok := integer(v)
+			return ok && n%divisor == remainder
+		}, nil
+	case OpRegex:
+		pattern, ok := arg.(string)
+		if !ok {
+			return nil, invalid()
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("selector: invalid regular expression: %w", err)
+		}
+		return func(v interface{}) bool {
+			s, ok := v.(string)
+			return ok && re.MatchString(s)
+		}, nil
+	}
+	return nil, fmt.Errorf("selector: unknown operator %s", op)
+}
+
+// field returns the value at path. found is false if the field is missing,
+// ok is false if the path runs through a value which is neither object nor array.
+func field(v interface{}, path []string) (value interface{}, found, ok bool) {
+	for _, name := range path {
+		switch t := v.(type) {
+		case map[string]interface{}:
+			if v, found = t[name]; !found {
+				return nil, false, true
+			}
+		case []interface{}:
+			i, err := strconv.Atoi(name)
+			if err != nil || i < 0 || i >= len(t) {
+				return nil, false, false
+			}
+			v = t[i]
+		default:
+			return nil, false, false
+		}
+	}
+	return v, true, true
+}
+
+// splitPath splits a field name at dots which are not escaped with a backslash.
+func splitPath(name string) []string {
+	var path []string
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '\\' && i+1 < len(name) && name[i+1] == '.':
+			b.WriteByte('.')
+			i++
+		case name[i] == '.':
+			path = append(path, b.String())
+			b.Reset()
+		default:
+			b.WriteByte(name[i])
+		}
+	}
+	return append(path, b.String())
+}
+
+func typeOf(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case json.Number:
+		return TypeNumber
+	case string:
+		return TypeString
+	case []interface{}:
+		return TypeArray
+	}
+	return TypeObject
+}
+
+// integer returns the value of an integral JSON number.
+func integer(v interface{}) (int64, bool) {
+	n, ok := v.(json.Number)
+	if !ok {
+		return 0, false
+	}
+	i, err := strconv.ParseInt(string(n), 10, 64)
+	return i, err == nil
+}
+
+// compareRaw compares strings by their bytes like CouchDB does for _id.
+func compareRaw(a, b interface{}) int {
+	if sa, ok := a.(string); ok {
+		if sb, ok := b.(string); ok {
+			return strings.Compare(sa, sb)
+		}
+	}
+	return couchdb.Collate(a, b)
+}
+
+// toJSON converts v to the values produced by decoding JSON with json.Number for numbers.
+func toJSON(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var value interface{}
+	return value, dec.Decode(&value)
+}
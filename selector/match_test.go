@@ -0,0 +1,208 @@
+package selector
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type player struct {
+	ID      string            `json:"_id"`
+	Name    string            `json:"name"`
+	Age     int               `json:"age"`
+	Score   float64           `json:"score"`
+	Tags    []string          `json:"tags"`
+	Address map[string]string `json:"address,omitempty"`
+	Games   []game            `json:"games"`
+}
+
+type game struct {
+	Score int `json:"score"`
+}
+
+func TestMatch(t *testing.T) {
+	doc := player{
+		ID:      "player:1",
+		Name:    "john",
+		Age:     21,
+		Score:   9.5,
+		Tags:    []string{"go", "couchdb"},
+		Address: map[string]string{"city": "Berlin", "zip.code": "10115"},
+		Games:   []game{{Score: 12}, {Score: 15}},
+	}
+	tests := []struct {
+		selector Selector
+		match    bool
+	}{
+		{Selector{}, true},
+		{Field("name").Eq("john"), true},
+		{Field("name").Eq("jane"), false},
+		{Field("name").Ne("jane"), true},
+		{Field("age").Gt(20), true},
+		{Field("age").Gt(21), false},
+		{Field("age").Gte(21), true},
+		{Field("age").Lt(21.5), true},
+		{Field("age").Lte(20), false},
+		{Field("age").Eq(21.0), true},
+		// numbers sort before strings
+		{Field("age").Lt("a"), true},
+		{Field("name").In("jane", "john"), true},
+		{Field("name").Nin("jane", "john"), false},
+		{Field("tags").In("go"), true},
+		{Field("tags").In("java"), false},
+		{Field("tags").Nin("java"), true},
+		{Field("tags").All("couchdb", "go"), true},
+		{Field("tags").All("couchdb", "java"), false},
+		{Field("tags").All([]string{"go", "couchdb"}), true},
+		{Field("tags").Size(2), true},
+		{Field("tags").Size(1), false},
+		{Field("tags").ElemMatch(Value().Eq("go")), true},
+		{Field("tags").AllMatch(Value().Regex("o")), true},
+		{Field("games").ElemMatch(Field("score").Gt(14)), true},
+		{Field("games").AllMatch(Field("score").Gt(14)), false},
+		{Field("name").ElemMatch(Value().Eq("john")), false},
+		{Field("name").Exists(true), true},
+		{Field("name").Exists(false), false},
+		{Field("name").Type(TypeString), true},
+		{Field("age").Type(TypeNumber), true},
+		{Field("tags").Type(TypeArray), true},
+		{Field("address").Type(TypeObject), true},
+		{Field("age").Mod(4, 1), true},
+		{Field("age").Mod(4, 0), false},
+		{Field("score").Mod(2, 1), false},
+		{Field("name").Regex("^jo"), true},
+		{Field("name").Regex("^ja"), false},
+		{Field("age").Regex("2"), false},
+		{Field("address.city").Eq("Berlin"), true},
+		{Field(`address.zip\.code`).Eq("10115"), true},
+		{Field("tags.0").Eq("go"), true},
+		{Field("tags.5").Eq("go"), false},
+		{Field("name.first").Exists(false), false},
+		{Field("_id").Gt("player:0"), true},
+		// missing fields only match $exists false
+		{Field("missing").Exists(false), true},
+		{Field("missing").Exists(true), false},
+		{Field("missing").Ne("john"), false},
+		{Field("missing").Nin("john"), false},
+		{Field("missing").Eq(nil), false},
+		{Not(Field("missing").Eq("john")), true},
+		{Field("age").Gt(20).And(Field("name").Eq("john")), true},
+		{Field("age").Gt(30).And(Field("name").Eq("john")), false},
+		{Field("age").Gt(30).Or(Field("name").Eq("john")), true},
+		{Or(), false},
+		{Nor(Field("age").Gt(30), Field("name").Eq("jane")), true},
+		{Nor(Field("age").Gt(20)), false},
+		{Not(Field("age").Gt(20)), false},
+	}
+	for _, tt := range tests {
+		match, err := tt.selector.Match(doc)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.selector, err)
+		}
+		if match != tt.match {
+			t.Errorf("%s: expected %t but got %t", tt.selector, tt.match, match)
+		}
+	}
+}
+
+func TestMatchInvalid(t *testing.T) {
+	tests := []Selector{
+		Field("age").Mod(0, 1),
+		Field("age").Size(-1),
+		Field("name").Regex("("),
+		Field("age").condition("$unknown", 1),
+		Field("age").condition(OpIn, "a"),
+		Field("age").condition(OpExists, "yes"),
+	}
+	for _, s := range tests {
+		if _, err := s.Match(map[string]interface{}{}); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		json     string
+		selector Selector
+	}{
+		{`{}`, Selector{}},
+		{`{"name": "john"}`, Field("name").Eq("john")},
+		{`{"age": {"$gt": 21}}`, Field("age").Gt(json.Number("21"))},
+		{
+			`{"name": "john", "age": {"$gt": 21, "$lt": 30}}`,
+			And(
+				And(Field("age").Gt(json.Number("21")), Field("age").Lt(json.Number("30"))),
+				Field("name").Eq("john"),
+			),
+		},
+		{`{"address": {"city": "Berlin"}}`, Field("address.city").Eq("Berlin")},
+		{`{"tags": ["go"]}`, Field("tags").Eq([]interface{}{"go"})},
+		{`{"age": {"$not": {"$gt": 21}}}`, Not(Field("age").Gt(json.Number("21")))},
+		{
+			`{"age": {"$or": [21, {"$gt": 30}]}}`,
+			Or(Field("age").Eq(json.Number("21")), Field("age").Gt(json.Number("30"))),
+		},
+		{
+			`{"$or": [{"name": "john"}, {"name": "jane"}]}`,
+			Or(Field("name").Eq("john"), Field("name").Eq("jane")),
+		},
+		{
+			`{"games": {"$elemMatch": {"score": {"$gt": 10}}}}`,
+			Field("games").ElemMatch(Field("score").Gt(json.Number("10"))),
+		},
+		{
+			`{"tags": {"$elemMatch": {"$eq": "go"}}}`,
+			Field("tags").ElemMatch(Value().Eq("go")),
+		},
+	}
+	for _, tt := range tests {
+		s, err := Parse([]byte(tt.json))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.json, err)
+		}
+		if s.String() != tt.selector.String() {
+			t.Errorf("%s: expected %s but got %s", tt.json, tt.selector, s)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []string{
+		`[]`,
+		`{"$and": {}}`,
+		`{"$not": []}`,
+		`{"tags": {"$elemMatch": "go"}}`,
+		`{"age": {"$unknown": 1}}`,
+		`{"age": {"$mod": [0, 1]}}`,
+		`{"tags": {"$in": "go"}}`,
+	}
+	for _, data := range tests {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected error", data)
+		}
+	}
+}
+
+func TestUnmarshalJSON(t *testing.T) {
+	var query struct {
+		Selector Selector `json:"selector"`
+	}
+	data := `{"selector":{"$or":[{"age":{"$gt":21}},{"tags":{"$size":2}}]}}`
+	if err := json.Unmarshal([]byte(data), &query); err != nil {
+		t.Fatal(err)
+	}
+	b, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != data {
+		t.Errorf("expected %s but got %s", data, b)
+	}
+	match, err := query.Selector.Match(map[string]interface{}{"tags": []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match {
+		t.Error("expected selector to match")
+	}
+}
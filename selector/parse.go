@@ -0,0 +1,125 @@
+package selector
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Parse parses a Mango selector from JSON. The selector is normalized the way CouchDB
+// does it: values without operator are implicit $eq conditions, objects with several
+// keys are implicit $and combinations and nested objects become dotted field names.
+//
+//	s, err := selector.Parse([]byte(`{"type": "player", "age": {"$gt": 21}}`))
+func Parse(data []byte) (Selector, error) {
+	var v interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return Selector{}, err
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Selector{}, fmt.Errorf("selector: selector must be an object but got %s", data)
+	}
+	return ParseMap(m)
+}
+
+// ParseMap is like Parse but takes a decoded JSON object.
+func ParseMap(m map[string]interface{}) (Selector, error) {
+	s, err := parse(m, "")
+	if err != nil {
+		return Selector{}, err
+	}
+	// validates the arguments of all conditions
+	if _, err := compile(s); err != nil {
+		return Selector{}, err
+	}
+	return s, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *Selector) UnmarshalJSON(data []byte) error {
+	parsed, err := Parse(data)
+	if err != nil {
+		return err
+	}
+	*s = parsed
+	return nil
+}
+
+// parse normalizes v found below the given field path.
+func parse(v interface{}, path string) (Selector, error) {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return Selector{op: OpEq, field: path, arg: v}, nil
+	}
+	switch len(m) {
+	case 0:
+		return Selector{}, nil
+	case 1:
+	default:
+		keys := make([]string, 0, len(m))
+		for key := range m {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		selectors := make([]Selector, len(keys))
+		for i, key := range keys {
+			s, err := parse(map[string]interface{}{key: m[key]}, path)
+			if err != nil {
+				return Selector{}, err
+			}
+			selectors[i] = s
+		}
+		return And(selectors...), nil
+	}
+	var key string
+	var arg interface{}
+	for k, a := range m {
+		key, arg = k, a
+	}
+	switch key {
+	case OpAnd, OpOr, OpNor:
+		args, ok := arg.([]interface{})
+		if !ok {
+			return Selector{}, fmt.Errorf("selector: %s requires an array", key)
+		}
+		selectors := make([]Selector, len(args))
+		for i, a := range args {
+			s, err := parse(a, path)
+			if err != nil {
+				return Selector{}, err
+			}
+			selectors[i] = s
+		}
+		return Selector{op: key, selectors: selectors}, nil
+	case OpNot:
+		if _, ok := arg.(map[string]interface{}); !ok {
+			return Selector{}, fmt.Errorf("selector: %s requires an object", key)
+		}
+		s, err := parse(arg, path)
+		if err != nil {
+			return Selector{}, err
+		}
+		return Not(s), nil
+	case OpElemMatch, OpAllMatch:
+		if _, ok := arg.(map[string]interface{}); !ok {
+			return Selector{}, fmt.Errorf("selector: %s requires an object", key)
+		}
+		s, err := parse(arg, "")
+		if err != nil {
+			return Selector{}, err
+		}
+		return Selector{op: key, field: path, arg: s}, nil
+	}
+	if strings.HasPrefix(key, "$") {
+		return Selector{op: key, field: path, arg: arg}, nil
+	}
+	if path != "" {
+		key = path + "." + key
+	}
+	return parse(arg, key)
+}
@@ -0,0 +1,171 @@
+// Package selector builds Mango selectors.
+//
+// Selectors marshal to the JSON accepted by _find, partial filter selectors of
+// indexes, replication selectors and the _selector filter of the _changes feed.
+//
+//	s := selector.Field("age").Gt(21).And(selector.Field("type").Eq("player"))
+//	res, err := db.Find(couchdb.FindQuery{Selector: s}, &players)
+package selector
+
+import (
+	"encoding/json"
+)
+
+// Combination operators.
+const (
+	OpAnd = "$and"
+	OpOr  = "$or"
+	OpNor = "$nor"
+	OpNot = "$not"
+)
+
+// Condition operators.
+const (
+	OpEq        = "$eq"
+	OpNe        = "$ne"
+	OpGt        = "$gt"
+	OpGte       = "$gte"
+	OpLt        = "$lt"
+	OpLte       = "$lte"
+	OpIn        = "$in"
+	OpNin       = "$nin"
+	OpExists    = "$exists"
+	OpType      = "$type"
+	OpSize      = "$size"
+	OpMod       = "$mod"
+	OpRegex     = "$regex"
+	OpAll       = "$all"
+	OpElemMatch = "$elemMatch"
+	OpAllMatch  = "$allMatch"
+)
+
+// Types used by the $type operator.
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeNumber  = "number"
+	TypeString  = "string"
+	TypeArray   = "array"
+	TypeObject  = "object"
+)
+
+// Selector is a node of a Mango selector. It is either a condition on a field
+// like {"age": {"$gt": 21}} or a combination of other selectors.
+// The zero value is the empty selector {} which matches all documents.
+type Selector struct {
+	op    string
+	field string
+	// arg is the argument of a condition. It is a Selector for $elemMatch and $allMatch.
+	arg interface{}
+	// selectors are the operands of combinations.
+	selectors []Selector
+}
+
+// IsZero reports whether s is the empty selector.
+func (s Selector) IsZero() bool {
+	return s.op == ""
+}
+
+// And combines s with other selectors using $and.
+func (s Selector) And(selectors ...Selector) Selector {
+	return And(append([]Selector{s}, selectors...)...)
+}
+
+// Or combines s with other selectors using $or.
+func (s Selector) Or(selectors ...Selector) Selector {
+	return Or(append([]Selector{s}, selectors...)...)
+}
+
+// And matches documents matching all selectors.
+// Empty selectors are skipped and nested $and selectors are flattened.
+func And(selectors ...Selector) Selector {
+	return combine(OpAnd, selectors)
+}
+
+// Or matches documents matching any of the selectors.
+func Or(selectors ...Selector) Selector {
+	return combine(OpOr, selectors)
+}
+
+// Nor matches documents matching none of the selectors.
+func Nor(selectors ...Selector) Selector {
+	return Selector{op: OpNor, selectors: selectors}
+}
+
+// Not matches documents not matching s.
+func Not(s Selector) Selector {
+	return Selector{op: OpNot, selectors: []Selector{s}}
+}
+
+func combine(op string, selectors []Selector) Selector {
+	var operands []Selector
+	for _, s := range selectors {
+		switch {
+		case s.IsZero():
+			continue
+		case s.op == op:
+			operands = append(operands, s.selectors...)
+		default:
+			operands = append(operands, s)
+		}
+	}
+	if len(operands) == 1 {
+		return operands[0]
+	}
+	return Selector{op: op, selectors: operands}
+}
+
+// Op returns the operator of s, e.g. "$and" or "$gt". It is empty for the empty selector.
+func (s Selector) Op() string {
+	return s.op
+}
+
+// FieldName returns the field name of a condition. It is empty for combinations
+// and for conditions on the value itself.
+func (s Selector) FieldName() string {
+	return s.field
+}
+
+// Arg returns the argument of a condition.
+func (s Selector) Arg() interface{} {
+	return s.arg
+}
+
+// Selectors returns the operands of a combination.
+func (s Selector) Selectors() []Selector {
+	return s.selectors
+}
+
+// MarshalJSON implements json.Marshaler.
+func (s Selector) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.object())
+}
+
+// String returns the JSON encoding of s.
+func (s Selector) String() string {
+	b, err := s.MarshalJSON()
+	if err != nil {
+		return "%!(" + err.Error() + ")"
+	}
+	return string(b)
+}
+
+func (s Selector) object() map[string]interface{} {
+	switch s.op {
+	case "":
+		return map[string]interface{}{}
+	case OpAnd, OpOr, OpNor:
+		operands := make([]interface{}, len(s.selectors))
+		for i, operand := range s.selectors {
+			operands[i] = operand.object()
+		}
+		return map[string]interface{}{s.op: operands}
+	case OpNot:
+		return map[string]interface{}{s.op: s.selectors[0].object()}
+	}
+	condition := map[string]interface{}{s.op: s.arg}
+	if s.field == "" {
+		return condition
+	}
+	return map[string]interface{}{s.field: condition}
+}
@@ -0,0 +1,89 @@
+package selector
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMarshalJSON(t *testing.T) {
+	tests := []struct {
+		selector Selector
+		json     string
+	}{
+		{Selector{}, `{}`},
+		{Field("type").Eq("player"), `{"type":{"$eq":"player"}}`},
+		{Field("age").Ne(nil), `{"age":{"$ne":null}}`},
+		{Field("age").Gt(21), `{"age":{"$gt":21}}`},
+		{Field("age").Gte(21), `{"age":{"$gte":21}}`},
+		{Field("age").Lt(21), `{"age":{"$lt":21}}`},
+		{Field("age").Lte(21.5), `{"age":{"$lte":21.5}}`},
+		{Field("tags").In("go", "couchdb"), `{"tags":{"$in":["go","couchdb"]}}`},
+		{Field("tags").Nin(), `{"tags":{"$nin":[]}}`},
+		{Field("deleted").Exists(false), `{"deleted":{"$exists":false}}`},
+		{Field("name").Type(TypeString), `{"name":{"$type":"string"}}`},
+		{Field("tags").Size(2), `{"tags":{"$size":2}}`},
+		{Field("count").Mod(3, 1), `{"count":{"$mod":[3,1]}}`},
+		{Field("name").Regex("^j"), `{"name":{"$regex":"^j"}}`},
+		{Field("tags").All("a", 1), `{"tags":{"$all":["a",1]}}`},
+		{Field("address.city").Eq("Berlin"), `{"address.city":{"$eq":"Berlin"}}`},
+		{
+			Field("tags").ElemMatch(Value().Eq("go")),
+			`{"tags":{"$elemMatch":{"$eq":"go"}}}`,
+		},
+		{
+			Field("games").AllMatch(Field("score").Gt(10)),
+			`{"games":{"$allMatch":{"score":{"$gt":10}}}}`,
+		},
+		{
+			Field("age").Gt(21).And(Field("type").Eq("player")),
+			`{"$and":[{"age":{"$gt":21}},{"type":{"$eq":"player"}}]}`,
+		},
+		{
+			Or(Field("a").Eq(1), Field("b").Eq(2)),
+			`{"$or":[{"a":{"$eq":1}},{"b":{"$eq":2}}]}`,
+		},
+		{
+			Nor(Field("a").Eq(1)),
+			`{"$nor":[{"a":{"$eq":1}}]}`,
+		},
+		{
+			Not(Field("a").Eq(1)),
+			`{"$not":{"a":{"$eq":1}}}`,
+		},
+		// nested $and is flattened and empty selectors are skipped
+		{
+			And(Field("a").Eq(1).And(Field("b").Eq(2)), Selector{}, Field("c").Eq(3)),
+			`{"$and":[{"a":{"$eq":1}},{"b":{"$eq":2}},{"c":{"$eq":3}}]}`,
+		},
+		{And(Selector{}, Field("a").Eq(1)), `{"a":{"$eq":1}}`},
+		{
+			Field("a").Eq(1).Or(And(Field("b").Eq(2), Field("c").Eq(3))),
+			`{"$or":[{"a":{"$eq":1}},{"$and":[{"b":{"$eq":2}},{"c":{"$eq":3}}]}]}`,
+		},
+	}
+	for _, tt := range tests {
+		b, err := json.Marshal(tt.selector)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != tt.json {
+			t.Errorf("expected %s but got %s", tt.json, b)
+		}
+	}
+}
+
+func TestMarshalJSONEmbedded(t *testing.T) {
+	query := struct {
+		Selector interface{} `json:"selector"`
+	}{
+		Selector: Field("age").Gt(21),
+	}
+	b, err := json.Marshal(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"selector":{"age":{"$gt":21}}}`
+	if string(b) != expected {
+		t.Errorf("expected %s but got %s", expected, b)
+	}
+}
@@ -0,0 +1,202 @@
+package couchdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Stages of a staged design document deploy.
+const (
+	StageUpload  = "upload"
+	StageIndex   = "index"
+	StageCopy    = "copy"
+	StageCleanup = "cleanup"
+)
+
+const stagingSuffix = "_staging"
+
+// stagedPollInterval is how often ActiveTasks is polled while a view is indexed.
+var stagedPollInterval = time.Second
+
+// StagedProgress reports the progress of a staged design document deploy.
+type StagedProgress struct {
+	// Database is the name of the database the design document is deployed to.
+	Database string
+	// DesignDocument is the ID of the live design document, e.g. "_design/player".
+	DesignDocument string
+	// Stage is one of StageUpload, StageIndex, StageCopy and StageCleanup.
+	Stage string
+	// View is the view which is indexed during StageIndex.
+	View string
+	// ChangesDone and TotalChanges sum up the indexer tasks of all shards.
+	// Both are zero until an indexer shows up in the active tasks.
+	ChangesDone  int
+	TotalChanges int
+}
+
+// SeedStaged deploys new and changed design documents without blocking queries on
+// rebuilding indexes. Every design document is uploaded as _design/<name>_staging first
+// and its views are queried until they are indexed. Then the staging design document is
+// copied over the live one, which reuses the built indexes, and deleted.
+// Progress is reported to progress, which may be nil. SeedAll calls it for many
+// databases but never concurrently.
+// http://docs.couchdb.org/en/latest/best-practices/views.html#deploying-a-view-change-in-a-live-environment
+func SeedStaged(progress func(StagedProgress)) SeedOption {
+	return func(o *seedOptions) {
+		o.staged = true
+		o.progress = progress
+	}
+}
+
+// deployStaged deploys doc through a staging design document.
+// liveRev is the revision of the live design document or empty if it does not exist.
+func (db *Database) deployStaged(ctx context.Context, doc DesignDocument, liveRev string, progress func(StagedProgress)) error {
+	report := func(p StagedProgress) {
+		if progress != nil {
+			p.Database = db.Name
+			p.DesignDocument = doc.ID
+			progress(p)
+		}
+	}
+	staging := doc
+	staging.ID = doc.ID + stagingSuffix
+	staging.Rev = ""
+	report(StagedProgress{Stage: StageUpload})
+	// overwrite a staging design document left over by an aborted deploy
+	var leftover DesignDocument
+	if err := db.GetContext(ctx, &leftover, staging.ID); err == nil {
+		staging.Rev = leftover.Rev
+	} else if !IsNotFound(err) {
+		return err
+	}
+	res, err := db.PutContext(ctx, &staging)
+	if err != nil {
+		return err
+	}
+	staging.Rev = res.Rev
+	// build the indexes of all views
+	names := make([]string, 0, len(staging.Views))
+	for name := range staging.Views {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := db.warmUp(ctx, staging.ID, name, report); err != nil {
+			return err
+		}
+	}
+	// copy the staging design document over the live one
+	report(StagedProgress{Stage: StageCopy})
+	destination := doc.ID
+	if liveRev != "" {
+		destination += "?rev=" + url.QueryEscape(liveRev)
+	}
+	header := http.Header{}
+	header.Set("Destination", destination)
+	u := fmt.Sprintf("%s/%s", url.PathEscape(db.Name), url.PathEscape(staging.ID))
+	copyRes, err := db.Client.request(ctx, "COPY", u, nil, header)
+	if err != nil {
+		return err
+	}
+	copyRes.Body.Close()
+	report(StagedProgress{Stage: StageCleanup})
+	_, err = db.DeleteContext(ctx, &staging)
+	return err
+}
+
+// warmUp queries a view of the staging design document until its index is built.
+// Meanwhile the indexer progress is polled from the active tasks.
+func (db *Database) warmUp(ctx context.Context, ddocID, view string, report func(StagedProgress)) error {
+	v := db.View(strings.TrimPrefix(ddocID, "_design/"))
+	limit := 0
+	params := QueryParameters{Limit: &limit}
+	report(StagedProgress{Stage: StageIndex, View: view})
+	ticker := time.NewTicker(stagedPollInterval)
+	defer ticker.Stop()
+	for {
+		done := make(chan error, 1)
+		go func() {
+			_, err := v.GetContext(ctx, view, params)
+			done <- err
+		}()
+	poll:
+		for {
+			select {
+			case err := <-done:
+				if err == nil {
+					return nil
+				}
+				// the request timed out while the index is still built, query again
+				if timeout(err) && ctx.Err() == nil {
+					if err := sleep(ctx, stagedPollInterval); err != nil {
+						return err
+					}
+					break poll
+				}
+				return err
+			case <-ticker.C:
+				tasks, err := db.Client.ActiveTasksContext(ctx)
+				if err != nil {
+					// progress is informational, e.g. non-admins may not see tasks
+					continue
+				}
+				p := indexerProgress(tasks, db.Name, ddocID)
+				p.Stage = StageIndex
+				p.View = view
+				report(p)
+			}
+		}
+	}
+}
+
+// timeout reports whether a request failed because it took too long, either in the
+// client or in the cluster. Other errors like refused connections are not retried.
+func timeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var cerr *Error
+	if errors.As(err, &cerr) {
+		return cerr.Type == "timeout"
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
+
+// indexerProgress sums up the indexer tasks of a design document in all shards of a database.
+func indexerProgress(tasks []Task, dbName, ddocID string) StagedProgress {
+	var p StagedProgress
+	for _, task := range tasks {
+		if task.Type != "indexer" || task.DesignDocument != ddocID || taskDatabase(task.Database) != dbName {
+			continue
+		}
+		p.ChangesDone += task.ChangesDone
+		p.TotalChanges += task.TotalChanges
+	}
+	return p
+}
+
+// taskDatabase returns the database name of a task database,
+// which is a shard like "shards/00000000-7fffffff/players.1234567890" in a cluster.
+func taskDatabase(name string) string {
+	if !strings.HasPrefix(name, "shards/") {
+		return name
+	}
+	parts := strings.SplitN(name, "/", 3)
+	if len(parts) < 3 {
+		return name
+	}
+	name = parts[2]
+	// database names cannot contain dots, so the last one starts the shard suffix
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
@@ -3,14 +3,18 @@ package couchdb
 // Task describes currently running task.
 // http://docs.couchdb.org/en/latest/api/server/common.html#active-tasks
 type Task struct {
-	ChangesDone  int `json:"changes_done"`
-	Database     string
-	Pid          string
-	Progress     int
-	StartedOn    int `json:"started_on"`
-	Status       string
-	Task         string
-	TotalChanges int `json:"total_changes"`
-	Type         string
-	UpdatedOn    int `json:"updated_on"`
+	ChangesDone int `json:"changes_done"`
+	// Database is the name of the database or of a database shard
+	// like "shards/00000000-7fffffff/players.1234567890".
+	Database string
+	// DesignDocument is the design document of indexer tasks.
+	DesignDocument string `json:"design_document"`
+	Pid            string
+	Progress       int
+	StartedOn      int `json:"started_on"`
+	Status         string
+	Task           string
+	TotalChanges   int `json:"total_changes"`
+	Type           string
+	UpdatedOn      int `json:"updated_on"`
 }
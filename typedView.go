@@ -0,0 +1,107 @@
+package couchdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+)
+
+// TypedRow is a row of a view or _all_docs response with typed key, value and doc.
+type TypedRow[K, V, D any] struct {
+	ID    string `json:"id"`
+	Key   K      `json:"key"`
+	Value V      `json:"value"`
+	// Doc is nil unless documents are included and exist.
+	Doc *D `json:"doc,omitempty"`
+	// Error is set for keys of _all_docs which do not exist, e.g. "not_found".
+	Error string `json:"error,omitempty"`
+}
+
+// TypedViewResponse is the response of QueryView and QueryAllDocs.
+type TypedViewResponse[K, V, D any] struct {
+	Offset    int                 `json:"offset"`
+	Rows      []TypedRow[K, V, D] `json:"rows"`
+	TotalRows int                 `json:"total_rows"`
+	UpdateSeq Sequence            `json:"update_seq,omitempty"`
+}
+
+// AllDocsValue is the value of _all_docs rows.
+type AllDocsValue struct {
+	Rev     string `json:"rev"`
+	Deleted bool   `json:"deleted,omitempty"`
+}
+
+// QueryView executes a view and decodes keys, values and documents of all rows into the
+// given types. Numbers decoded into interface{} are json.Number to keep their precision.
+//
+//	res, err := couchdb.QueryView[[]interface{}, int, Player](db.View("player"), "byAge", params)
+//	for _, row := range res.Rows {
+//		fmt.Println(row.Key, row.Value, row.Doc.Name)
+//	}
+func QueryView[K, V, D any](v ViewService, name string, params QueryParameters) (*TypedViewResponse[K, V, D], error) {
+	return QueryViewContext[K, V, D](context.Background(), v, name, params)
+}
+
+// QueryViewContext is like QueryView but takes a context.
+func QueryViewContext[K, V, D any](ctx context.Context, v ViewService, name string, params QueryParameters) (*TypedViewResponse[K, V, D], error) {
+	rows, err := v.RowsContext(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[K, V, D](rows)
+}
+
+// QueryAllDocs is like QueryView for _all_docs. The documents are decoded into D.
+func QueryAllDocs[D any](db DatabaseService, params *QueryParameters) (*TypedViewResponse[string, AllDocsValue, D], error) {
+	return QueryAllDocsContext[D](context.Background(), db, params)
+}
+
+// QueryAllDocsContext is like QueryAllDocs but takes a context.
+func QueryAllDocsContext[D any](ctx context.Context, db DatabaseService, params *QueryParameters) (*TypedViewResponse[string, AllDocsValue, D], error) {
+	rows, err := db.AllDocsRowsContext(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	return collectRows[string, AllDocsValue, D](rows)
+}
+
+// collectRows reads and closes rows.
+func collectRows[K, V, D any](rows *Rows) (*TypedViewResponse[K, V, D], error) {
+	defer rows.Close()
+	res := &TypedViewResponse[K, V, D]{
+		Rows: []TypedRow[K, V, D]{},
+	}
+	for rows.Next() {
+		row := TypedRow[K, V, D]{
+			ID:    rows.row.ID,
+			Error: rows.row.Error,
+		}
+		if err := decodeNumbers(rows.row.Key, &row.Key); err != nil {
+			return nil, err
+		}
+		if err := decodeNumbers(rows.row.Value, &row.Value); err != nil {
+			return nil, err
+		}
+		if err := decodeNumbers(rows.row.Doc, &row.Doc); err != nil {
+			return nil, err
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	res.Offset = rows.Offset()
+	res.TotalRows = rows.TotalRows()
+	res.UpdateSeq = rows.UpdateSeq()
+	return res, nil
+}
+
+// decodeNumbers decodes data into v using json.Number for numbers. Empty data is skipped.
+func decodeNumbers(data json.RawMessage, v interface{}) error {
+	if data == nil {
+		return nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -11,6 +11,7 @@ import (
 	"net/textproto"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/zemirco/uid"
 )
@@ -21,21 +22,46 @@ func mimeType(name string) string {
 	return mime.TypeByExtension(ext)
 }
 
+// maxErrorBody is the number of bytes of an error response body kept in Error.Body.
+const maxErrorBody = 1024
+
 // Convert HTTP response from CouchDB into Error.
+// The status code is never lost. Responses without a JSON body, like HEAD responses
+// or HTML pages from proxies, fall back to the status text.
 func newError(res *http.Response) error {
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		return err
-	}
 	defer res.Body.Close()
-	error := &Error{}
-	err = json.Unmarshal(body, &error)
-	if err != nil {
-		return err
+	error := &Error{
+		StatusCode: res.StatusCode,
+		Header:     res.Header,
+		RequestID:  res.Header.Get("X-Couch-Request-ID"),
+	}
+	if res.Request != nil {
+		error.Method = res.Request.Method
+		error.URL = res.Request.URL.String()
+	}
+	// read a little more than we keep to parse slightly larger JSON bodies
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, 64*maxErrorBody))
+	if len(body) > maxErrorBody {
+		error.Body = string(body[:maxErrorBody])
+	} else {
+		error.Body = string(body)
+	}
+	var content struct {
+		Error  string          `json:"error"`
+		Reason json.RawMessage `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &content); err == nil && content.Error != "" {
+		error.Type = content.Error
+		// reason is usually a string but may be any JSON value
+		if err := json.Unmarshal(content.Reason, &error.Reason); err != nil {
+			error.Reason = string(content.Reason)
+		}
+		return error
 	}
-	error.Method = res.Request.Method
-	error.URL = res.Request.URL.String()
-	error.StatusCode = res.StatusCode
+	// e.g. "Not Found" becomes "not_found" like CouchDB error types
+	text := http.StatusText(res.StatusCode)
+	error.Type = strings.ToLower(strings.ReplaceAll(text, " ", "_"))
+	error.Reason = text
 	return error
 }
 
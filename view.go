@@ -2,17 +2,30 @@ package couchdb
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-
-	"github.com/google/go-querystring/query"
+	"net/url"
 )
 
 // ViewService is an interface for dealing with a view inside a CouchDB database.
 type ViewService interface {
 	Get(name string, params QueryParameters) (*ViewResponse, error)
-	Post(name string, keys []string, params QueryParameters) (*ViewResponse, error)
+	GetContext(ctx context.Context, name string, params QueryParameters) (*ViewResponse, error)
+	Post(name string, keys []interface{}, params QueryParameters) (*ViewResponse, error)
+	PostContext(ctx context.Context, name string, keys []interface{}, params QueryParameters) (*ViewResponse, error)
+	Rows(name string, params QueryParameters) (*Rows, error)
+	RowsContext(ctx context.Context, name string, params QueryParameters) (*Rows, error)
+	Queries(name string, queries []QueryParameters) ([]ViewResponse, error)
+	QueriesContext(ctx context.Context, name string, queries []QueryParameters) ([]ViewResponse, error)
+	Show(name, docID string, params url.Values) (*HandlerResponse, error)
+	ShowContext(ctx context.Context, name, docID string, params url.Values) (*HandlerResponse, error)
+	List(name, view string, params QueryParameters) (*HandlerResponse, error)
+	ListContext(ctx context.Context, name, view string, params QueryParameters) (*HandlerResponse, error)
+	Update(name, docID string, params url.Values, body io.Reader, contentType string) (*HandlerResponse, error)
+	UpdateContext(ctx context.Context, name, docID string, params url.Values, body io.Reader, contentType string) (*HandlerResponse, error)
 }
 
 // View performs actions and certain view documents
@@ -23,12 +36,12 @@ type View struct {
 
 // Get executes specified view function from specified design document.
 func (v *View) Get(name string, params QueryParameters) (*ViewResponse, error) {
-	q, err := query.Values(params)
-	if err != nil {
-		return nil, err
-	}
-	uri := fmt.Sprintf("%s_view/%s?%s", v.URL, name, q.Encode())
-	res, err := v.Client.Request(http.MethodGet, uri, nil, "")
+	return v.GetContext(context.Background(), name, params)
+}
+
+// GetContext is like Get but takes a context.
+func (v *View) GetContext(ctx context.Context, name string, params QueryParameters) (*ViewResponse, error) {
+	res, err := v.get(ctx, name, params)
 	if err != nil {
 		return nil, err
 	}
@@ -37,12 +50,41 @@ func (v *View) Get(name string, params QueryParameters) (*ViewResponse, error) {
 	return &response, json.NewDecoder(res.Body).Decode(&response)
 }
 
+// Rows executes specified view function like Get but returns an iterator
+// which decodes one row at a time.
+func (v *View) Rows(name string, params QueryParameters) (*Rows, error) {
+	return v.RowsContext(context.Background(), name, params)
+}
+
+// RowsContext is like Rows but takes a context.
+func (v *View) RowsContext(ctx context.Context, name string, params QueryParameters) (*Rows, error) {
+	res, err := v.get(ctx, name, params)
+	if err != nil {
+		return nil, err
+	}
+	return newRows(res.Body)
+}
+
+func (v *View) get(ctx context.Context, name string, params QueryParameters) (*http.Response, error) {
+	q, err := params.values()
+	if err != nil {
+		return nil, err
+	}
+	uri := fmt.Sprintf("%s_view/%s?%s", v.URL, name, q.Encode())
+	return v.Client.RequestContext(ctx, http.MethodGet, uri, nil, "")
+}
+
 // Post executes specified view function from specified design document.
 // Unlike View.Get for accessing views, View.Post supports
 // the specification of explicit keys to be retrieved from the view results.
-func (v *View) Post(name string, keys []string, params QueryParameters) (*ViewResponse, error) {
+func (v *View) Post(name string, keys []interface{}, params QueryParameters) (*ViewResponse, error) {
+	return v.PostContext(context.Background(), name, keys, params)
+}
+
+// PostContext is like Post but takes a context.
+func (v *View) PostContext(ctx context.Context, name string, keys []interface{}, params QueryParameters) (*ViewResponse, error) {
 	content := struct {
-		Keys []string `json:"keys"`
+		Keys []interface{} `json:"keys"`
 	}{
 		Keys: keys,
 	}
@@ -51,13 +93,14 @@ func (v *View) Post(name string, keys []string, params QueryParameters) (*ViewRe
 	if err := json.NewEncoder(&b).Encode(content); err != nil {
 		return nil, err
 	}
-	// create query string
-	q, err := query.Values(params)
+	// create query string, keys are sent in the body
+	params.Keys = nil
+	q, err := params.values()
 	if err != nil {
 		return nil, err
 	}
 	url := fmt.Sprintf("%s_view/%s?%s", v.URL, name, q.Encode())
-	res, err := v.Client.Request(http.MethodPost, url, &b, "application/json")
+	res, err := v.Client.RequestContext(ctx, http.MethodPost, url, &b, "application/json")
 	if err != nil {
 		return nil, err
 	}
@@ -65,3 +108,40 @@ func (v *View) Post(name string, keys []string, params QueryParameters) (*ViewRe
 	var response ViewResponse
 	return &response, json.NewDecoder(res.Body).Decode(&response)
 }
+
+// Queries executes several queries against a view in a single request
+// and returns one response per query in the same order.
+// http://docs.couchdb.org/en/latest/api/ddoc/views.html#sending-multiple-queries-to-a-view
+func (v *View) Queries(name string, queries []QueryParameters) ([]ViewResponse, error) {
+	return v.QueriesContext(context.Background(), name, queries)
+}
+
+// QueriesContext is like Queries but takes a context.
+func (v *View) QueriesContext(ctx context.Context, name string, queries []QueryParameters) ([]ViewResponse, error) {
+	return v.Client.queries(ctx, fmt.Sprintf("%s_view/%s/queries", v.URL, name), queries)
+}
+
+// queries posts multiple queries to a view or _all_docs.
+func (c *Client) queries(ctx context.Context, uri string, queries []QueryParameters) ([]ViewResponse, error) {
+	if queries == nil {
+		queries = []QueryParameters{}
+	}
+	content := struct {
+		Queries []QueryParameters `json:"queries"`
+	}{
+		Queries: queries,
+	}
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(content); err != nil {
+		return nil, err
+	}
+	res, err := c.RequestContext(ctx, http.MethodPost, uri, &b, "application/json")
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	var response struct {
+		Results []ViewResponse `json:"results"`
+	}
+	return response.Results, json.NewDecoder(res.Body).Decode(&response)
+}